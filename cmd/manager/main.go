@@ -16,6 +16,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 	"strings"
@@ -26,46 +27,75 @@ import (
 	"github.com/uswitch/nidhogg/pkg/controller"
 	"github.com/uswitch/nidhogg/pkg/nidhogg"
 	"github.com/uswitch/nidhogg/pkg/webhook"
+	"k8s.io/apimachinery/pkg/types"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 var (
 	metricsAddr        string
 	configPath         string
+	policyName         string
 	leaderElection     bool
 	leaderConfigMap    string
 	leaderNamespace    string
 	clientRequestQPS   float64
 	clientRequestBurst int
 	disableCompression bool
+	maxConcurrent      int
+	enableWebhooks     bool
+	webhookPort        int
+	webhookCertDir     string
+	webhookService     string
+	webhookConfigName  string
+	tolerationNs       string
+	dryRun             bool
 )
 
 func main() {
 
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&configPath, "config-file", "config.json", "Path to config file")
+	flag.StringVar(&policyName, "policy-name", "", "Name of the NidhoggPolicy to take the configuration from instead of the config file")
 	flag.BoolVar(&leaderElection, "leader-election", false, "enable leader election")
 	flag.StringVar(&leaderConfigMap, "leader-configmap", "", "Name of configmap to use for leader election")
 	flag.StringVar(&leaderNamespace, "leader-namespace", "", "Namespace where leader configmap located")
 	flag.Float64Var(&clientRequestQPS, "kube-api-qps", 20.0, "QPS rate for throttling requests sent to the Kubernetes API server")
 	flag.IntVar(&clientRequestBurst, "kube-api-burst", 30, "Maximum burst for throttling requests sent to the Kubernetes API server")
 	flag.BoolVar(&disableCompression, "disable-compression", true, "Disable response compression for k8s restAPI in client-go")
+	flag.IntVar(&maxConcurrent, "max-concurrent-reconciles", 1, "Maximum number of nodes reconciled in parallel")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the admission webhooks, provisioning their certificate in the secret named by SECRET_NAME")
+	flag.IntVar(&webhookPort, "webhook-port", 9876, "Port the webhook server listens on")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "/tmp/cert", "Directory the webhook certificate is written to")
+	flag.StringVar(&webhookService, "webhook-service", "nidhogg", "Name of the service in front of the webhook server")
+	flag.StringVar(&webhookConfigName, "webhook-config-name", "nidhogg", "Name of the webhook configurations to inject the CA bundle into")
+	flag.StringVar(&tolerationNs, "toleration-namespaces", "", "Comma separated namespaces whose pods get tolerations for every nidhogg taint, requires --enable-webhooks")
+	flag.BoolVar(&dryRun, "dry-run", false, "Only log, report as events and count in metrics the taint changes instead of applying them to the nodes")
 	flag.Parse()
 	logf.SetLogger(zap.New())
 	log := logf.Log.WithName("entrypoint")
 
 	handlerConf, err := nidhogg.GetConfig(configPath)
 	if err != nil {
-		log.Error(err, "unable to get config")
-		os.Exit(1)
+		if policyName == "" {
+			log.Error(err, "unable to get config")
+			os.Exit(1)
+		}
+		// the policy will provide the configuration once it is reconciled
+		log.Info("no usable config file, waiting for policy", "policy", policyName, "reason", err.Error())
+		handlerConf = nidhogg.HandlerConfig{}
+		_ = handlerConf.BuildSelectors()
 	}
 
-	if handlerConf.NodeSelector == nil {
+	if policyName != "" {
+		log.Info("configuration will be taken from policy", "policy", policyName)
+	} else if handlerConf.NodeSelector == nil {
 		log.Info("looking for nodes that will match daemonsets selectors")
 	} else {
 		log.Info("looking for nodes that match provided node selector", "selector", strings.Join(handlerConf.NodeSelector, ","))
@@ -89,6 +119,7 @@ func main() {
 		LeaderElection:          leaderElection,
 		LeaderElectionID:        leaderConfigMap,
 		LeaderElectionNamespace: leaderNamespace,
+		WebhookServer:           ctrlwebhook.NewServer(ctrlwebhook.Options{Port: webhookPort, CertDir: webhookCertDir}),
 	})
 	if err != nil {
 		log.Error(err, "unable to set up overall controller manager")
@@ -106,17 +137,40 @@ func main() {
 
 	// Setup all Controllers
 	log.Info("Setting up controller")
-	if err := controller.AddToManager(mgr, handlerConf); err != nil {
+	handler := nidhogg.NewHandler(mgr.GetClient(), mgr.GetEventRecorderFor("nidhogg"), handlerConf)
+	handler.SetAPIReader(mgr.GetAPIReader())
+	if dryRun {
+		log.Info("running dry, taint changes are only reported")
+		handler.SetDryRun(true)
+	}
+	if err := controller.AddToManager(mgr, controller.Options{Handler: handler, PolicyName: policyName, MaxConcurrentReconciles: maxConcurrent}); err != nil {
 		log.Error(err, "unable to register controllers to the manager")
 		os.Exit(1)
 	}
 
-	log.Info("setting up webhooks")
-	if err := webhook.AddToManager(mgr); err != nil {
-		log.Error(err, "unable to register webhooks to the manager")
+	if err := mgr.Add(nidhogg.NewGaugeUpdater(handler)); err != nil {
+		log.Error(err, "unable to register node gauges")
 		os.Exit(1)
 	}
 
+	// The policy replaces the config file when it is used
+	if policyName == "" {
+		log.Info("setting up config file watcher")
+		pod := types.NamespacedName{Name: os.Getenv("POD_NAME"), Namespace: os.Getenv("POD_NAMESPACE")}
+		if err := mgr.Add(nidhogg.NewConfigWatcher(configPath, handler, pod)); err != nil {
+			log.Error(err, "unable to watch the config file")
+			os.Exit(1)
+		}
+	}
+
+	if enableWebhooks {
+		log.Info("setting up webhooks")
+		if err := setupWebhooks(mgr, handler); err != nil {
+			log.Error(err, "unable to register webhooks to the manager")
+			os.Exit(1)
+		}
+	}
+
 	// Start the Cmd
 	log.Info("Starting the Cmd.")
 	if err := mgr.Start(signals.SetupSignalHandler()); err != nil {
@@ -124,3 +178,29 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// setupWebhooks provisions the webhook certificate before the webhook server starts and registers the webhooks
+func setupWebhooks(mgr manager.Manager, handler *nidhogg.Handler) error {
+	// the cache of the manager isn't started yet
+	c, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		return err
+	}
+	caBundle, err := webhook.ProvisionCerts(context.Background(), c, webhook.CertOptions{
+		CertDir:         webhookCertDir,
+		SecretName:      os.Getenv("SECRET_NAME"),
+		SecretNamespace: os.Getenv("POD_NAMESPACE"),
+		ServiceName:     webhookService,
+	})
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(webhook.NewCAInjector(c, webhookConfigName, caBundle)); err != nil {
+		return err
+	}
+	var namespaces []string
+	if tolerationNs != "" {
+		namespaces = strings.Split(tolerationNs, ",")
+	}
+	return webhook.AddToManager(mgr, webhook.Options{Handler: handler, TolerationNamespaces: namespaces})
+}
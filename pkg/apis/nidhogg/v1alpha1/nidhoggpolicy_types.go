@@ -0,0 +1,199 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// PolicyConditionAccepted reports whether the policy spec was valid and is used by the controller
+	PolicyConditionAccepted = "Accepted"
+)
+
+// NidhoggPolicySpec defines the daemonsets nidhogg waits for and how nodes are tainted meanwhile
+type NidhoggPolicySpec struct {
+	// TaintNamePrefix is the prefix of the taint keys, defaults to nidhogg.uswitch.com
+	// +optional
+	TaintNamePrefix string `json:"taintNamePrefix,omitempty"`
+	// TaintEffect is the effect of the taints, defaults to NoSchedule
+	// +kubebuilder:validation:Enum=NoSchedule;PreferNoSchedule;NoExecute
+	// +optional
+	TaintEffect string `json:"taintEffect,omitempty"`
+	// TaintRemovalDelayInSeconds is the delay to apply before removing a taint once its daemonset pod is ready
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TaintRemovalDelayInSeconds int `json:"taintRemovalDelayInSeconds,omitempty"`
+	// Daemonsets are the daemonsets which must have a ready pod on a node before it is untainted
+	// +optional
+	Daemonsets []Daemonset `json:"daemonsets,omitempty"`
+	// Discovery adds the daemonsets annotated with nidhogg.uswitch.com/required to the daemonsets of the policy
+	// +optional
+	Discovery DaemonsetDiscovery `json:"discovery,omitempty"`
+	// Groups set how many daemonsets of a group must be ready on a node, all of them for a group which isn't listed
+	// +optional
+	Groups []DaemonsetGroup `json:"groups,omitempty"`
+	// NodeSelector restricts the nodes to act on, the daemonsets' own node selectors are used when empty
+	// +optional
+	NodeSelector []string `json:"nodeSelector,omitempty"`
+	// ReadySincePolicy tells whether the ready-since annotation of a node keeps the first time it was ready,
+	// or is cleared when the node is tainted again, defaults to FirstReady
+	// +kubebuilder:validation:Enum=FirstReady;LastReady
+	// +optional
+	ReadySincePolicy string `json:"readySincePolicy,omitempty"`
+	// StartupOnly only taints nodes until the daemonsets are first ready on them, later unreadiness is only reported
+	// +optional
+	StartupOnly bool `json:"startupOnly,omitempty"`
+	// RequireCurrentRevision keeps a node tainted until its daemonset pods run the current revision of their daemonset,
+	// nodes which were already ready for a daemonset are left alone during its rollouts
+	// +optional
+	RequireCurrentRevision bool `json:"requireCurrentRevision,omitempty"`
+}
+
+// Daemonset references a daemonset watched by nidhogg
+type Daemonset struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// TaintEffect overrides the taint effect of the policy for this daemonset
+	// +kubebuilder:validation:Enum=NoSchedule;PreferNoSchedule;NoExecute
+	// +optional
+	TaintEffect string `json:"taintEffect,omitempty"`
+	// TaintRemovalDelayInSeconds overrides the taint removal delay of the policy for this daemonset
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TaintRemovalDelayInSeconds *int `json:"taintRemovalDelayInSeconds,omitempty"`
+	// NodeSelector overrides the node selector of the policy for this daemonset
+	// +optional
+	NodeSelector []string `json:"nodeSelector,omitempty"`
+	// StartupOnly overrides the startup only mode of the policy for this daemonset
+	// +optional
+	StartupOnly *bool `json:"startupOnly,omitempty"`
+	// Readiness tells when a pod of this daemonset is ready, defaults to its Ready condition
+	// +optional
+	Readiness *Readiness `json:"readiness,omitempty"`
+	// RequireCurrentRevision overrides the current revision requirement of the policy for this daemonset
+	// +optional
+	RequireCurrentRevision *bool `json:"requireCurrentRevision,omitempty"`
+	// DependsOn are daemonsets of the policy whose taint must be gone from a node before this daemonset counts as ready
+	// +optional
+	DependsOn []DaemonsetReference `json:"dependsOn,omitempty"`
+	// Group shares the taint of the daemonset with the other daemonsets of the group, it is removed once they are all ready
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	Group string `json:"group,omitempty"`
+	// Mode Audit only reports the taint changes for this daemonset instead of applying them, defaults to Enforce
+	// +kubebuilder:validation:Enum=Enforce;Audit
+	// +optional
+	Mode string `json:"mode,omitempty"`
+}
+
+// DaemonsetReference names another daemonset of the policy
+type DaemonsetReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// Readiness tells when a daemonset pod is ready for the taint of its daemonset to be removed
+type Readiness struct {
+	// Mode is one of Ready, ContainersReady, Containers, Conditions or Running, defaults to Ready
+	// +kubebuilder:validation:Enum=Ready;ContainersReady;Containers;Conditions;Running
+	// +optional
+	Mode string `json:"mode,omitempty"`
+	// Containers are the containers which must be ready with the Containers mode
+	// +optional
+	Containers []string `json:"containers,omitempty"`
+	// Conditions are the pod conditions which must be true with the Conditions mode
+	// +optional
+	Conditions []string `json:"conditions,omitempty"`
+}
+
+// DaemonsetDiscovery tells whether daemonsets can opt in with annotations, and from which namespaces
+type DaemonsetDiscovery struct {
+	// Enabled turns on the discovery of annotated daemonsets
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// NamespaceSelector restricts the discovery to the namespaces matching these label selectors, all namespaces when empty
+	// +optional
+	NamespaceSelector []string `json:"namespaceSelector,omitempty"`
+}
+
+// DaemonsetGroup tells how many daemonsets of a group must be ready on a node before the taint of the group is removed
+type DaemonsetGroup struct {
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// AnyOf removes the taint of the group once any of its daemonsets is ready, like MinReady set to 1
+	// +optional
+	AnyOf bool `json:"anyOf,omitempty"`
+	// MinReady is the number of daemonsets of the group which must be ready, capped to the ones required on the node
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinReady int `json:"minReady,omitempty"`
+}
+
+// DaemonsetStatus reports how many nodes are still waiting for a daemonset
+type DaemonsetStatus struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// MatchingNodes is the number of nodes the daemonset is required on
+	MatchingNodes int32 `json:"matchingNodes"`
+	// TaintedNodes is the number of matching nodes still carrying the daemonset taint
+	TaintedNodes int32 `json:"taintedNodes"`
+	// ClearNodes is the number of matching nodes without the daemonset taint
+	ClearNodes int32 `json:"clearNodes"`
+}
+
+// NidhoggPolicyStatus defines the observed state of NidhoggPolicy
+type NidhoggPolicyStatus struct {
+	// ObservedGeneration is the generation of the spec last processed by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Daemonsets holds the node counts of every daemonset in the policy
+	// +optional
+	Daemonsets []DaemonsetStatus `json:"daemonsets,omitempty"`
+	// Conditions reports whether the policy has been accepted
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NidhoggPolicy is the Schema for the nidhoggpolicies API
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+type NidhoggPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NidhoggPolicySpec   `json:"spec,omitempty"`
+	Status NidhoggPolicyStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NidhoggPolicyList contains a list of NidhoggPolicy
+type NidhoggPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NidhoggPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NidhoggPolicy{}, &NidhoggPolicyList{})
+}
@@ -17,9 +17,12 @@ package controller
 
 import (
 	"github.com/uswitch/nidhogg/pkg/controller/node"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
 func init() {
 	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
-	AddToManagerFuncs = append(AddToManagerFuncs, node.Add)
+	AddToManagerFuncs = append(AddToManagerFuncs, func(m manager.Manager, o Options) error {
+		return node.Add(m, o.Handler, o.MaxConcurrentReconciles)
+	})
 }
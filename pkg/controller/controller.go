@@ -20,13 +20,23 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
+// Options contains the dependencies and settings shared by all Controllers
+type Options struct {
+	// Handler performs the tainting of the nodes
+	Handler *nidhogg.Handler
+	// PolicyName is the name of the NidhoggPolicy to take the configuration from, the policy controller is disabled when empty
+	PolicyName string
+	// MaxConcurrentReconciles is the number of nodes reconciled in parallel
+	MaxConcurrentReconciles int
+}
+
 // AddToManagerFuncs is a list of functions to add all Controllers to the Manager
-var AddToManagerFuncs []func(manager.Manager, nidhogg.HandlerConfig) error
+var AddToManagerFuncs []func(manager.Manager, Options) error
 
 // AddToManager adds all Controllers to the Manager
-func AddToManager(m manager.Manager, n nidhogg.HandlerConfig) error {
+func AddToManager(m manager.Manager, o Options) error {
 	for _, f := range AddToManagerFuncs {
-		if err := f(m, n); err != nil {
+		if err := f(m, o); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,137 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"context"
+
+	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Add creates a new Discovery Controller keeping the daemonsets discovered from their annotations up to date in the handler.
+// It runs on every replica, so that standby replicas serve the webhooks with the same daemonsets as the leader.
+func Add(mgr manager.Manager, h *nidhogg.Handler) error {
+	return add(mgr, newReconciler(mgr, h), h)
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager, h *nidhogg.Handler) reconcile.Reconciler {
+	return &ReconcileDiscovery{Client: mgr.GetClient(), handler: h}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler, h *nidhogg.Handler) error {
+	needLeaderElection := false
+	c, err := controller.New("discovery-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: 1,
+		NeedLeaderElection:      &needLeaderElection,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Only the annotations of a daemonset tell whether it is required
+	err = c.Watch(source.Kind(mgr.GetCache(), &appsv1.DaemonSet{},
+		&handler.TypedEnqueueRequestForObject[*appsv1.DaemonSet]{},
+		predicate.TypedAnnotationChangedPredicate[*appsv1.DaemonSet]{}))
+	if err != nil {
+		return err
+	}
+
+	// The daemonsets of a namespace may start or stop matching the namespace selector when its labels change
+	err = c.Watch(source.Kind(mgr.GetCache(), &corev1.Namespace{},
+		handler.TypedEnqueueRequestsFromMapFunc(enqueueNamespaceDaemonsets(mgr.GetClient())),
+		predicate.TypedLabelChangedPredicate[*corev1.Namespace]{}))
+	if err != nil {
+		return err
+	}
+
+	// Discover every daemonset again when discovery is enabled or its namespace selector changes
+	return c.Watch(source.Channel(h.DiscoveryChanges(), handler.TypedEnqueueRequestsFromMapFunc(enqueueAllDaemonsets(mgr.GetClient()))))
+}
+
+// enqueueAllDaemonsets maps a change of the discovery settings to a request for every daemonset in the cluster
+func enqueueAllDaemonsets(c client.Client) handler.TypedMapFunc[nidhogg.DaemonsetDiscovery, reconcile.Request] {
+	return func(ctx context.Context, _ nidhogg.DaemonsetDiscovery) []reconcile.Request {
+		daemonsets := &appsv1.DaemonSetList{}
+		if err := c.List(ctx, daemonsets); err != nil {
+			logf.Log.Error(err, "unable to list daemonsets after discovery change")
+			return nil
+		}
+		return daemonsetRequests(daemonsets)
+	}
+}
+
+// enqueueNamespaceDaemonsets maps a change of the labels of a namespace to a request for each of its daemonsets
+func enqueueNamespaceDaemonsets(c client.Client) handler.TypedMapFunc[*corev1.Namespace, reconcile.Request] {
+	return func(ctx context.Context, namespace *corev1.Namespace) []reconcile.Request {
+		daemonsets := &appsv1.DaemonSetList{}
+		if err := c.List(ctx, daemonsets, client.InNamespace(namespace.Name)); err != nil {
+			logf.Log.Error(err, "unable to list daemonsets after namespace change", "namespace", namespace.Name)
+			return nil
+		}
+		return daemonsetRequests(daemonsets)
+	}
+}
+
+// daemonsetRequests returns a request for each of the daemonsets
+func daemonsetRequests(daemonsets *appsv1.DaemonSetList) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(daemonsets.Items))
+	for _, ds := range daemonsets.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: ds.Namespace,
+			Name:      ds.Name,
+		}})
+	}
+	return requests
+}
+
+// ReconcileDiscovery reconciles the annotations of a DaemonSet object
+var _ reconcile.Reconciler = &ReconcileDiscovery{}
+
+type ReconcileDiscovery struct {
+	client.Client
+	handler *nidhogg.Handler
+}
+
+// Reconcile records whether the daemonset is required through its annotations, forgetting it once it is deleted
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+func (r *ReconcileDiscovery) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	ds := &appsv1.DaemonSet{}
+	if err := r.Get(ctx, request.NamespacedName, ds); err != nil {
+		if errors.IsNotFound(err) {
+			r.handler.ForgetDaemonset(request.NamespacedName)
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	r.handler.DiscoverDaemonset(ctx, ds)
+	return reconcile.Result{}, nil
+}
@@ -0,0 +1,91 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// TestReconcileKeepsStandbyTolerationsUpToDate reconciles daemonsets without the node controller of the leader,
+// like a standby replica serving the webhooks
+func TestReconcileKeepsStandbyTolerationsUpToDate(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx := context.TODO()
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "team-a", Annotations: map[string]string{nidhogg.RequiredAnnotation: "true"}}}
+	fakeClient := fake.NewClientBuilder().WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}, ds).Build()
+	h := nidhogg.NewHandler(fakeClient, record.NewFakeRecorder(10), nidhogg.HandlerConfig{Discovery: nidhogg.DaemonsetDiscovery{Enabled: true}})
+	r := &ReconcileDiscovery{Client: fakeClient, handler: h}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: "agent", Namespace: "team-a"}}
+
+	g.Expect(h.Tolerations(nil, nil)).To(gomega.BeEmpty())
+
+	_, err := r.Reconcile(ctx, request)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(h.Tolerations(nil, nil)).To(gomega.Equal([]corev1.Toleration{
+		{Key: "nidhogg.uswitch.com/team-a.agent", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	}))
+
+	g.Expect(fakeClient.Delete(ctx, ds)).To(gomega.Succeed())
+	_, err = r.Reconcile(ctx, request)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(h.Tolerations(nil, nil)).To(gomega.BeEmpty())
+}
+
+func TestEnqueueNamespaceDaemonsets(t *testing.T) {
+	g := gomega.NewWithT(t)
+	fakeClient := fake.NewClientBuilder().WithObjects(
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "team-a"}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "team-b"}},
+	).Build()
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"team": "a"}}}
+
+	requests := enqueueNamespaceDaemonsets(fakeClient)(context.TODO(), namespace)
+	g.Expect(requests).To(gomega.Equal([]reconcile.Request{{NamespacedName: types.NamespacedName{Name: "agent", Namespace: "team-a"}}}))
+}
+
+func TestReconcileFollowsNamespaceLabels(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx := context.TODO()
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "team-a", Annotations: map[string]string{nidhogg.RequiredAnnotation: "true"}}}
+	fakeClient := fake.NewClientBuilder().WithObjects(namespace, ds).Build()
+	h := nidhogg.NewHandler(fakeClient, record.NewFakeRecorder(10), nidhogg.HandlerConfig{Discovery: nidhogg.DaemonsetDiscovery{Enabled: true, NamespaceSelector: []string{"nidhogg=enabled"}}})
+	r := &ReconcileDiscovery{Client: fakeClient, handler: h}
+
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: "agent", Namespace: "team-a"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(h.Tolerations(nil, nil)).To(gomega.BeEmpty())
+
+	// the daemonsets of the namespace are reconciled again once it is labeled
+	namespace.Labels = map[string]string{"nidhogg": "enabled"}
+	g.Expect(fakeClient.Update(ctx, namespace)).To(gomega.Succeed())
+	for _, request := range enqueueNamespaceDaemonsets(fakeClient)(ctx, namespace) {
+		_, err = r.Reconcile(ctx, request)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+	g.Expect(h.Tolerations(nil, nil)).To(gomega.HaveLen(1))
+}
@@ -17,44 +17,79 @@ package node
 
 import (
 	"context"
+	"maps"
+	"reflect"
+	"strings"
 
 	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // Add creates a new Node Controller and adds it to the Manager with default RBAC. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
-func Add(mgr manager.Manager, cfg nidhogg.HandlerConfig) error {
-	return add(mgr, newReconciler(mgr, cfg))
+func Add(mgr manager.Manager, h *nidhogg.Handler, maxConcurrentReconciles int) error {
+	return add(mgr, newReconciler(mgr, h), h, maxConcurrentReconciles)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager, cfg nidhogg.HandlerConfig) reconcile.Reconciler {
-	eventRecorder := mgr.GetEventRecorderFor("nidhogg")
-	reconcilerHandler := nidhogg.NewHandler(mgr.GetClient(), eventRecorder, cfg)
-	return &ReconcileNode{reconcilerHandler, mgr.GetScheme()}
+func newReconciler(mgr manager.Manager, h *nidhogg.Handler) reconcile.Reconciler {
+	return &ReconcileNode{h, mgr.GetScheme()}
+}
+
+// enqueueAllNodes maps a configuration change to a request for every node in the cluster
+func enqueueAllNodes(c client.Client) handler.TypedMapFunc[nidhogg.HandlerConfig, reconcile.Request] {
+	return func(ctx context.Context, _ nidhogg.HandlerConfig) []reconcile.Request {
+		nodes := &corev1.NodeList{}
+		if err := c.List(ctx, nodes); err != nil {
+			logf.Log.Error(err, "unable to list nodes after configuration change")
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(nodes.Items))
+		for _, node := range nodes.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name: node.Name,
+			}})
+		}
+		return requests
+	}
 }
 
 var _ handler.TypedEventHandler[*corev1.Node, reconcile.Request] = &nodeEnqueue{}
 
-type nodeEnqueue struct{}
+type nodeEnqueue struct {
+	handler *nidhogg.Handler
+}
 
-// Update implements the interface
-func (e *nodeEnqueue) Update(_ context.Context, _ event.TypedUpdateEvent[*corev1.Node], _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+// Update adds the updated node to the queue, nodeUpdatePredicate filters out the updates which are not relevant
+func (e *nodeEnqueue) Update(_ context.Context, evt event.TypedUpdateEvent[*corev1.Node], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	if evt.ObjectNew == nil {
+		return
+	}
+	q.Add(reconcile.Request{NamespacedName: types.NamespacedName{
+		Name: evt.ObjectNew.GetName(),
+	}})
 }
 
-// Delete implements the interface
-func (e *nodeEnqueue) Delete(_ context.Context, _ event.TypedDeleteEvent[*corev1.Node], _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+// Delete forgets what the handler remembers about the node, there is nothing left to reconcile
+func (e *nodeEnqueue) Delete(_ context.Context, evt event.TypedDeleteEvent[*corev1.Node], _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	if evt.Object == nil {
+		return
+	}
+	e.handler.ForgetNode(evt.Object.GetName())
 }
 
 // Generic implements the interface
@@ -71,6 +106,42 @@ func (e *nodeEnqueue) Create(_ context.Context, evt event.TypedCreateEvent[*core
 	}})
 }
 
+// nodeUpdatePredicate only lets through the node updates changing its labels, taints, Ready condition or nidhogg annotations,
+// ignoring the status updates sent by the kubelet heartbeats
+func nodeUpdatePredicate(h *nidhogg.Handler) predicate.TypedPredicate[*corev1.Node] {
+	return predicate.TypedFuncs[*corev1.Node]{
+		UpdateFunc: func(evt event.TypedUpdateEvent[*corev1.Node]) bool {
+			if evt.ObjectOld == nil || evt.ObjectNew == nil {
+				return false
+			}
+			prefix := h.TaintNamePrefix()
+			return !maps.Equal(evt.ObjectOld.Labels, evt.ObjectNew.Labels) ||
+				!reflect.DeepEqual(evt.ObjectOld.Spec.Taints, evt.ObjectNew.Spec.Taints) ||
+				nodeReady(evt.ObjectOld) != nodeReady(evt.ObjectNew) ||
+				!maps.Equal(prefixedAnnotations(evt.ObjectOld, prefix), prefixedAnnotations(evt.ObjectNew, prefix))
+		},
+	}
+}
+
+func nodeReady(node *corev1.Node) corev1.ConditionStatus {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status
+		}
+	}
+	return corev1.ConditionUnknown
+}
+
+func prefixedAnnotations(node *corev1.Node, prefix string) map[string]string {
+	annotations := make(map[string]string)
+	for key, value := range node.Annotations {
+		if strings.HasPrefix(key, prefix) {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
 var _ handler.TypedEventHandler[*corev1.Pod, reconcile.Request] = &podEnqueue{}
 
 type podEnqueue struct{}
@@ -134,19 +205,91 @@ func (e *podEnqueue) Delete(_ context.Context, evt event.TypedDeleteEvent[*corev
 	}})
 }
 
+var _ handler.TypedEventHandler[*appsv1.DaemonSet, reconcile.Request] = &daemonsetEnqueue{}
+
+// daemonsetEnqueue keeps the daemonset placements of the handler up to date, and adds the nodes whose match result changed to the queue.
+// The discovered daemonsets are kept up to date by the discovery controller.
+type daemonsetEnqueue struct {
+	client.Client
+	handler *nidhogg.Handler
+}
+
+// Create records the placement of the daemonset, the nodes it schedules pods on are added to the queue unless it was already known
+func (e *daemonsetEnqueue) Create(ctx context.Context, evt event.TypedCreateEvent[*appsv1.DaemonSet], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	if evt.Object == nil {
+		return
+	}
+	previous := e.handler.SetDaemonsetPlacement(evt.Object)
+	e.enqueueNodes(ctx, evt.Object, previous, nidhogg.NewDaemonsetPlacement(evt.Object), q)
+}
+
+// Update records the placement of the daemonset and adds the nodes it started or stopped scheduling pods on to the queue
+func (e *daemonsetEnqueue) Update(ctx context.Context, evt event.TypedUpdateEvent[*appsv1.DaemonSet], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	if evt.ObjectNew == nil {
+		return
+	}
+	previous := e.handler.SetDaemonsetPlacement(evt.ObjectNew)
+	e.enqueueNodes(ctx, evt.ObjectNew, previous, nidhogg.NewDaemonsetPlacement(evt.ObjectNew), q)
+}
+
+// Delete forgets the placement of the daemonset and adds the nodes it scheduled pods on to the queue, they don't wait for it anymore
+func (e *daemonsetEnqueue) Delete(ctx context.Context, evt event.TypedDeleteEvent[*appsv1.DaemonSet], q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	if evt.Object == nil {
+		return
+	}
+	previous := e.handler.ForgetDaemonsetPlacement(evt.Object)
+	if previous == nil {
+		previous = nidhogg.NewDaemonsetPlacement(evt.Object)
+	}
+	e.enqueueNodes(ctx, evt.Object, previous, nil, q)
+}
+
+// Generic implements the interface
+func (e *daemonsetEnqueue) Generic(_ context.Context, _ event.TypedGenericEvent[*appsv1.DaemonSet], _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+}
+
+// enqueueNodes adds the nodes scheduled by only one of the placements to the queue, when the nodes requiring the daemonset are picked by its placement.
+// A nil placement schedules no node.
+func (e *daemonsetEnqueue) enqueueNodes(ctx context.Context, ds *appsv1.DaemonSet, old, new *nidhogg.DaemonsetPlacement, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+	// status updates of the daemonset leave its placement alone
+	if reflect.DeepEqual(old, new) || !e.handler.UsesDaemonsetPlacement(types.NamespacedName{Namespace: ds.Namespace, Name: ds.Name}) {
+		return
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := e.List(ctx, nodes); err != nil {
+		logf.Log.Error(err, "unable to list nodes after daemonset change", "daemonset", ds.Name, "namespace", ds.Namespace)
+		return
+	}
+	prefix := e.handler.TaintNamePrefix()
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if old.Schedules(node, prefix) != new.Schedules(node, prefix) {
+			q.Add(reconcile.Request{NamespacedName: types.NamespacedName{
+				Name: node.Name,
+			}})
+		}
+	}
+}
+
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r reconcile.Reconciler, h *nidhogg.Handler, maxConcurrentReconciles int) error {
+	// Index the pods by node, so reconciles don't list whole namespaces
+	if err := nidhogg.IndexPodFields(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+
 	// Create a new controller
 	c, err := controller.New("node-controller", mgr, controller.Options{
 		Reconciler:              r,
-		MaxConcurrentReconciles: 1,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
 	})
 	if err != nil {
 		return err
 	}
 
 	// Watch for changes to Node
-	err = c.Watch(source.Kind(mgr.GetCache(), &corev1.Node{}, &nodeEnqueue{}))
+	err = c.Watch(source.Kind(mgr.GetCache(), &corev1.Node{}, &nodeEnqueue{handler: h}, nodeUpdatePredicate(h)))
 	if err != nil {
 		return err
 	}
@@ -156,6 +299,19 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Re-evaluate the nodes whose match result changed when a daemonset placement changes, status updates don't bump the generation
+	err = c.Watch(source.Kind(mgr.GetCache(), &appsv1.DaemonSet{}, &daemonsetEnqueue{Client: mgr.GetClient(), handler: h},
+		predicate.TypedGenerationChangedPredicate[*appsv1.DaemonSet]{}))
+	if err != nil {
+		return err
+	}
+
+	// Reconcile every node when the handler configuration is replaced
+	err = c.Watch(source.Channel(h.ConfigChanges(), handler.TypedEnqueueRequestsFromMapFunc(enqueueAllNodes(mgr.GetClient()))))
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -172,6 +328,9 @@ type ReconcileNode struct {
 // +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=nodes/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups=,resources=events,verbs=create;update;patch
 func (r *ReconcileNode) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	return r.handler.HandleNode(ctx, request)
@@ -22,13 +22,17 @@ import (
 
 	"github.com/onsi/gomega"
 	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -51,8 +55,9 @@ func TestReconcile(t *testing.T) {
 	handlerConfig := nidhogg.HandlerConfig{}
 	_ = handlerConfig.BuildSelectors()
 
-	recFn, requests := SetupTestReconcile(newReconciler(mgr, handlerConfig))
-	g.Expect(add(mgr, recFn)).NotTo(gomega.HaveOccurred())
+	h := nidhogg.NewHandler(mgr.GetClient(), mgr.GetEventRecorderFor("nidhogg"), handlerConfig)
+	recFn, requests := SetupTestReconcile(newReconciler(mgr, h))
+	g.Expect(add(mgr, recFn, h, 1)).NotTo(gomega.HaveOccurred())
 
 	_, cancel, mgrStopped := StartTestManager(mgr, g)
 
@@ -74,3 +79,90 @@ func TestReconcile(t *testing.T) {
 	g.Eventually(requests, timeout).Should(gomega.Receive(gomega.Equal(expectedRequest)))
 
 }
+
+func TestNodeUpdatePredicate(t *testing.T) {
+	g := gomega.NewWithT(t)
+	h := nidhogg.NewHandler(nil, nil, nidhogg.HandlerConfig{})
+	p := nodeUpdatePredicate(h)
+
+	oldNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: map[string]string{"pool": "a"}},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionTrue, LastHeartbeatTime: metav1.Now()},
+		}},
+	}
+
+	heartbeat := oldNode.DeepCopy()
+	heartbeat.Status.Conditions[0].LastHeartbeatTime = metav1.NewTime(time.Now().Add(time.Minute))
+	g.Expect(p.Update(event.TypedUpdateEvent[*corev1.Node]{ObjectOld: oldNode, ObjectNew: heartbeat})).To(gomega.BeFalse())
+
+	unrelatedAnnotation := oldNode.DeepCopy()
+	unrelatedAnnotation.Annotations = map[string]string{"example.com/foo": "bar"}
+	g.Expect(p.Update(event.TypedUpdateEvent[*corev1.Node]{ObjectOld: oldNode, ObjectNew: unrelatedAnnotation})).To(gomega.BeFalse())
+
+	relabelled := oldNode.DeepCopy()
+	relabelled.Labels["pool"] = "b"
+	g.Expect(p.Update(event.TypedUpdateEvent[*corev1.Node]{ObjectOld: oldNode, ObjectNew: relabelled})).To(gomega.BeTrue())
+
+	tainted := oldNode.DeepCopy()
+	tainted.Spec.Taints = []corev1.Taint{{Key: "foo", Effect: corev1.TaintEffectNoSchedule}}
+	g.Expect(p.Update(event.TypedUpdateEvent[*corev1.Node]{ObjectOld: oldNode, ObjectNew: tainted})).To(gomega.BeTrue())
+
+	notReady := oldNode.DeepCopy()
+	notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+	g.Expect(p.Update(event.TypedUpdateEvent[*corev1.Node]{ObjectOld: oldNode, ObjectNew: notReady})).To(gomega.BeTrue())
+
+	annotated := oldNode.DeepCopy()
+	annotated.Annotations = map[string]string{"nidhogg.uswitch.com/ready-since": "2006-01-02T15:04:05Z"}
+	g.Expect(p.Update(event.TypedUpdateEvent[*corev1.Node]{ObjectOld: oldNode, ObjectNew: annotated})).To(gomega.BeTrue())
+}
+
+func TestDaemonsetEnqueueChangedNodes(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx := context.TODO()
+	fakeClient := fake.NewClientBuilder().WithObjects(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"pool": "a"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "b", Labels: map[string]string{"pool": "b"}}},
+	).Build()
+	h := nidhogg.NewHandler(fakeClient, nil, nidhogg.HandlerConfig{Daemonsets: []nidhogg.Daemonset{{Name: "kiam", Namespace: "kube-system"}}})
+	e := &daemonsetEnqueue{Client: fakeClient, handler: h}
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer q.ShutDown()
+
+	ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "kiam", Namespace: "kube-system"}}
+	ds.Spec.Template.Spec.NodeSelector = map[string]string{"pool": "a"}
+	e.Create(ctx, event.TypedCreateEvent[*appsv1.DaemonSet]{Object: ds}, q)
+	g.Expect(drain(q)).To(gomega.ConsistOf("a"))
+
+	resynced := ds.DeepCopy()
+	resynced.Status.NumberReady = 1
+	e.Update(ctx, event.TypedUpdateEvent[*appsv1.DaemonSet]{ObjectOld: ds, ObjectNew: resynced}, q)
+	g.Expect(drain(q)).To(gomega.BeEmpty())
+
+	moved := ds.DeepCopy()
+	moved.Spec.Template.Spec.NodeSelector = map[string]string{"pool": "b"}
+	e.Update(ctx, event.TypedUpdateEvent[*appsv1.DaemonSet]{ObjectOld: ds, ObjectNew: moved}, q)
+	g.Expect(drain(q)).To(gomega.ConsistOf("a", "b"))
+
+	e.Delete(ctx, event.TypedDeleteEvent[*appsv1.DaemonSet]{Object: moved}, q)
+	g.Expect(drain(q)).To(gomega.ConsistOf("b"))
+
+	e.Create(ctx, event.TypedCreateEvent[*appsv1.DaemonSet]{Object: moved}, q)
+	g.Expect(drain(q)).To(gomega.ConsistOf("b"))
+
+	other := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "kube-system"}}
+	e.Create(ctx, event.TypedCreateEvent[*appsv1.DaemonSet]{Object: other}, q)
+	g.Expect(drain(q)).To(gomega.BeEmpty())
+}
+
+// drain returns the names of the nodes in the queue, emptying it
+func drain(q workqueue.TypedRateLimitingInterface[reconcile.Request]) []string {
+	var names []string
+	for q.Len() > 0 {
+		request, _ := q.Get()
+		names = append(names, request.Name)
+		q.Done(request)
+		q.Forget(request)
+	}
+	return names
+}
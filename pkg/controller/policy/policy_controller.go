@@ -0,0 +1,192 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/uswitch/nidhogg/pkg/apis/nidhogg/v1alpha1"
+	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// statusResyncPeriod is how often the node counts of the policy status are refreshed
+const statusResyncPeriod = time.Minute
+
+// Add creates a new Policy Controller feeding the configuration of the named NidhoggPolicy to the handler.
+// It runs on every replica, so that standby replicas serve the webhooks with the same configuration as the leader,
+// only the leader updates the status of the policy. Nothing is added to the Manager when policyName is empty.
+func Add(mgr manager.Manager, h *nidhogg.Handler, policyName string) error {
+	if policyName == "" {
+		return nil
+	}
+	return add(mgr, newReconciler(mgr, h, policyName))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager, h *nidhogg.Handler, policyName string) reconcile.Reconciler {
+	return &ReconcilePolicy{
+		Client:     mgr.GetClient(),
+		handler:    h,
+		policyName: policyName,
+		fallback:   h.Config(),
+		elected:    mgr.Elected(),
+	}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	needLeaderElection := false
+	c, err := controller.New("policy-controller", mgr, controller.Options{
+		Reconciler:              r,
+		MaxConcurrentReconciles: 1,
+		NeedLeaderElection:      &needLeaderElection,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Status updates don't bump the generation, so they don't trigger a reconcile
+	return c.Watch(source.Kind(mgr.GetCache(), &v1alpha1.NidhoggPolicy{},
+		&handler.TypedEnqueueRequestForObject[*v1alpha1.NidhoggPolicy]{},
+		predicate.TypedGenerationChangedPredicate[*v1alpha1.NidhoggPolicy]{}))
+}
+
+// ReconcilePolicy reconciles a NidhoggPolicy object
+var _ reconcile.Reconciler = &ReconcilePolicy{}
+
+type ReconcilePolicy struct {
+	client.Client
+	handler    *nidhogg.Handler
+	policyName string
+	// fallback is the configuration used when the policy doesn't exist
+	fallback nidhogg.HandlerConfig
+	// applied is the last policy spec given to the handler
+	applied *v1alpha1.NidhoggPolicySpec
+	// elected is closed once the replica is the leader, standby replicas leave the status alone
+	elected <-chan struct{}
+}
+
+// Reconcile applies the spec of the NidhoggPolicy to the handler and reports the node counts in its status
+// +kubebuilder:rbac:groups=nidhogg.uswitch.com,resources=nidhoggpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=nidhogg.uswitch.com,resources=nidhoggpolicies/status,verbs=get;update;patch
+func (r *ReconcilePolicy) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	log := logf.Log.WithName("policy")
+
+	if request.Name != r.policyName {
+		return reconcile.Result{}, nil
+	}
+
+	policy := &v1alpha1.NidhoggPolicy{}
+	err := r.Get(ctx, request.NamespacedName, policy)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if r.applied != nil {
+				log.Info("Policy deleted, falling back to the config file", "policy", r.policyName)
+				r.handler.SetConfig(r.fallback)
+				r.applied = nil
+			}
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	status := policy.Status.DeepCopy()
+	status.ObservedGeneration = policy.Generation
+
+	conf, err := nidhogg.ConfigFromPolicy(policy)
+	if err != nil {
+		// keep the last accepted configuration until the policy is fixed
+		log.Error(err, "Invalid policy, keeping the current configuration", "policy", r.policyName)
+		if !r.isLeader() {
+			return reconcile.Result{RequeueAfter: statusResyncPeriod}, nil
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               v1alpha1.PolicyConditionAccepted,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InvalidSpec",
+			Message:            err.Error(),
+			ObservedGeneration: policy.Generation,
+		})
+		return reconcile.Result{}, r.updateStatus(ctx, policy, status)
+	}
+
+	if r.applied == nil || !reflect.DeepEqual(*r.applied, policy.Spec) {
+		log.Info("Applying policy", "policy", r.policyName, "generation", policy.Generation)
+		r.handler.SetConfig(conf)
+		r.applied = policy.Spec.DeepCopy()
+	}
+	if !r.isLeader() {
+		// checked again later, in case the replica takes over
+		return reconcile.Result{RequeueAfter: statusResyncPeriod}, nil
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               v1alpha1.PolicyConditionAccepted,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Applied",
+		Message:            "Policy is used to taint nodes",
+		ObservedGeneration: policy.Generation,
+	})
+
+	counts, err := r.handler.DaemonsetNodeCounts(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	status.Daemonsets = make([]v1alpha1.DaemonsetStatus, 0, len(counts))
+	for _, count := range counts {
+		status.Daemonsets = append(status.Daemonsets, v1alpha1.DaemonsetStatus{
+			Name:          count.Name,
+			Namespace:     count.Namespace,
+			MatchingNodes: int32(count.Matching),
+			TaintedNodes:  int32(count.Tainted),
+			ClearNodes:    int32(count.Matching - count.Tainted),
+		})
+	}
+
+	if err := r.updateStatus(ctx, policy, status); err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: statusResyncPeriod}, nil
+}
+
+func (r *ReconcilePolicy) isLeader() bool {
+	select {
+	case <-r.elected:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *ReconcilePolicy) updateStatus(ctx context.Context, policy *v1alpha1.NidhoggPolicy, status *v1alpha1.NidhoggPolicyStatus) error {
+	if reflect.DeepEqual(policy.Status, *status) {
+		return nil
+	}
+	policy.Status = *status
+	return r.Status().Update(ctx, policy)
+}
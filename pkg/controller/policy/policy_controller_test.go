@@ -0,0 +1,60 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"github.com/uswitch/nidhogg/pkg/apis"
+	"github.com/uswitch/nidhogg/pkg/apis/nidhogg/v1alpha1"
+	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileAppliesPolicyOnStandby(t *testing.T) {
+	g := gomega.NewWithT(t)
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	g.Expect(apis.AddToScheme(scheme)).To(gomega.Succeed())
+	policy := &v1alpha1.NidhoggPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Generation: 1},
+		Spec:       v1alpha1.NidhoggPolicySpec{Daemonsets: []v1alpha1.Daemonset{{Name: "kiam", Namespace: "kube-system"}}},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(policy).WithStatusSubresource(policy).Build()
+	h := nidhogg.NewHandler(fakeClient, record.NewFakeRecorder(10), nidhogg.HandlerConfig{})
+	// never elected
+	r := &ReconcilePolicy{Client: fakeClient, handler: h, policyName: "default", elected: make(chan struct{})}
+
+	result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: "default"}})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(result.RequeueAfter).To(gomega.Equal(statusResyncPeriod))
+
+	// the webhook of the standby tolerates the taints of the policy, its status is left to the leader
+	g.Expect(h.Tolerations(nil, nil)).To(gomega.Equal([]corev1.Toleration{
+		{Key: "nidhogg.uswitch.com/kube-system.kiam", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	}))
+	updated := &v1alpha1.NidhoggPolicy{}
+	g.Expect(fakeClient.Get(ctx, types.NamespacedName{Name: "default"}, updated)).To(gomega.Succeed())
+	g.Expect(updated.Status.Conditions).To(gomega.BeEmpty())
+}
@@ -0,0 +1,240 @@
+package nidhogg
+
+import (
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// DaemonsetModeEnforce taints the nodes for the daemonset, the default
+	DaemonsetModeEnforce = "Enforce"
+	// DaemonsetModeAudit only reports the taint changes nidhogg would make for the daemonset
+	DaemonsetModeAudit = "Audit"
+
+	// a taint would have been added or removed if it wasn't audited, see mode and --dry-run
+	taintOperationWouldAdd    = "would-add"
+	taintOperationWouldRemove = "would-remove"
+)
+
+// auditedTaint is what an audited taint and its annotations would look like on a node if it was enforced
+type auditedTaint struct {
+	taint       *corev1.Taint
+	annotations map[string]string
+}
+
+// SetDryRun makes the handler audit every taint, nodes are left untouched and the taint changes are only reported.
+// It must be called before nodes are reconciled.
+func (h *Handler) SetDryRun(dryRun bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dryRun = dryRun
+	h.pruneAuditedState()
+}
+
+// auditedTaints returns the taints whose changes are only reported, along with the nidhogg taints left on the node
+// by a previous configuration when running dry
+func (h *Handler) auditedTaints(node *corev1.Node) []string {
+	audited := h.configuredAuditedTaints()
+	if h.dryRun {
+		for _, taint := range node.Spec.Taints {
+			if strings.HasPrefix(taint.Key, h.getTaintNamePrefix()) && !slices.Contains(audited, taint.Key) {
+				audited = append(audited, taint.Key)
+			}
+		}
+	}
+	return audited
+}
+
+// configuredAuditedTaints returns the taints of the configured daemonsets whose changes are only reported
+func (h *Handler) configuredAuditedTaints() []string {
+	var audited []string
+	for _, daemonset := range h.config.Daemonsets {
+		taint := h.getTaintName(daemonset)
+		if h.isAudited(daemonset) && !slices.Contains(audited, taint) {
+			audited = append(audited, taint)
+		}
+	}
+	return audited
+}
+
+// isAudited tells whether the taint changes of the daemonset are only reported
+func (h *Handler) isAudited(daemonset Daemonset) bool {
+	return h.dryRun || daemonset.Mode == DaemonsetModeAudit
+}
+
+// auditedDaemonsets returns the configured daemonsets whose taint is only reported, enforced daemonsets don't wait for them
+func (h *Handler) auditedDaemonsets() map[types.NamespacedName]bool {
+	audited := make(map[types.NamespacedName]bool)
+	for _, daemonset := range h.config.Daemonsets {
+		if h.isAudited(daemonset) {
+			audited[daemonset.key()] = true
+		}
+	}
+	return audited
+}
+
+// pruneAuditedState forgets the state of the taints which aren't audited anymore, they start again from the state of the nodes
+// if they are audited later. When running dry, the nidhogg taints left on the nodes are audited as well. The caller must hold the lock.
+func (h *Handler) pruneAuditedState() {
+	audited := h.configuredAuditedTaints()
+	prefix := h.getTaintNamePrefix()
+
+	h.auditMu.Lock()
+	defer h.auditMu.Unlock()
+	for node, states := range h.audited {
+		for taint := range states {
+			if !slices.Contains(audited, taint) && (!h.dryRun || !strings.HasPrefix(taint, prefix)) {
+				delete(states, taint)
+			}
+		}
+		if len(states) == 0 {
+			delete(h.audited, node)
+		}
+	}
+}
+
+// ForgetNode drops the state of the audited taints of a node once it is deleted
+func (h *Handler) ForgetNode(node string) {
+	h.storeAuditedState(node, nil)
+}
+
+// withAuditedState returns a copy of the node showing the audited taints and their annotations as they would be if they were
+// enforced. An audited taint which wasn't seen yet starts from the state of the node.
+func (h *Handler) withAuditedState(node *corev1.Node, audited []string) *corev1.Node {
+	nodeCopy := node.DeepCopy()
+
+	h.auditMu.Lock()
+	defer h.auditMu.Unlock()
+	for taint, state := range h.audited[node.Name] {
+		if !slices.Contains(audited, taint) {
+			continue
+		}
+		nodeCopy.Spec.Taints = removeTaint(nodeCopy.Spec.Taints, taint)
+		if state.taint != nil {
+			nodeCopy.Spec.Taints = append(nodeCopy.Spec.Taints, *state.taint)
+		}
+		for _, key := range taintAnnotationKeys(taint) {
+			delete(nodeCopy.Annotations, key)
+			if value, ok := state.annotations[key]; ok {
+				setAnnotation(nodeCopy, key, value)
+			}
+		}
+	}
+	return nodeCopy
+}
+
+// revertAuditedTaints puts the audited taints and their annotations of the updated node back as they are on the node,
+// turning their changes into would-add and would-remove. It returns the state of the audited taints to remember.
+func revertAuditedTaints(node, updatedNode *corev1.Node, changes *taintChanges, audited []string) map[string]auditedTaint {
+	states := make(map[string]auditedTaint, len(audited))
+	for _, taint := range audited {
+		state := auditedTaint{annotations: make(map[string]string)}
+		if i := slices.IndexFunc(updatedNode.Spec.Taints, func(t corev1.Taint) bool { return t.Key == taint }); i >= 0 {
+			wouldBe := updatedNode.Spec.Taints[i]
+			state.taint = &wouldBe
+		}
+		for _, key := range taintAnnotationKeys(taint) {
+			if value, ok := updatedNode.Annotations[key]; ok {
+				state.annotations[key] = value
+			}
+			if value, ok := node.Annotations[key]; ok {
+				setAnnotation(updatedNode, key, value)
+			} else {
+				delete(updatedNode.Annotations, key)
+			}
+		}
+		states[taint] = state
+	}
+	if len(updatedNode.Annotations) == 0 && node.Annotations == nil {
+		updatedNode.Annotations = nil
+	}
+
+	// keep the order of the taints of the node, so that only the enforced changes make a difference
+	var taints []corev1.Taint
+	for _, taint := range node.Spec.Taints {
+		if slices.Contains(audited, taint.Key) {
+			taints = append(taints, taint)
+		} else if i := slices.IndexFunc(updatedNode.Spec.Taints, func(t corev1.Taint) bool { return t.Key == taint.Key }); i >= 0 {
+			taints = append(taints, updatedNode.Spec.Taints[i])
+		}
+	}
+	for _, taint := range updatedNode.Spec.Taints {
+		if !slices.Contains(audited, taint.Key) && !hasTaint(node.Spec.Taints, taint.Key) {
+			taints = append(taints, taint)
+		}
+	}
+	if taints == nil && node.Spec.Taints != nil {
+		taints = []corev1.Taint{}
+	}
+	updatedNode.Spec.Taints = taints
+
+	changes.taintsAdded, changes.taintsWouldAdd = splitAudited(changes.taintsAdded, audited)
+	changes.taintsRemoved, changes.taintsWouldRemove = splitAudited(changes.taintsRemoved, audited)
+	return states
+}
+
+// splitAudited separates the audited taints from the enforced ones
+func splitAudited(taints, audited []string) (enforced, wouldChange []string) {
+	for _, taint := range taints {
+		if slices.Contains(audited, taint) {
+			wouldChange = append(wouldChange, taint)
+		} else {
+			enforced = append(enforced, taint)
+		}
+	}
+	return enforced, wouldChange
+}
+
+// storeAuditedState remembers the state of the audited taints of the node, forgetting it when the node is gone
+func (h *Handler) storeAuditedState(node string, states map[string]auditedTaint) {
+	h.auditMu.Lock()
+	defer h.auditMu.Unlock()
+
+	if len(states) == 0 {
+		delete(h.audited, node)
+		return
+	}
+	if h.audited == nil {
+		h.audited = make(map[string]map[string]auditedTaint)
+	}
+	h.audited[node] = states
+}
+
+// reportAuditedTaints tells about the taint changes which weren't applied because their taints are audited
+func (h *Handler) reportAuditedTaints(node *corev1.Node, changes taintChanges) {
+	for _, taint := range changes.taintsWouldAdd {
+		taintOperations.WithLabelValues(taintOperationWouldAdd, taint).Inc()
+	}
+	for _, taint := range changes.taintsWouldRemove {
+		taintOperations.WithLabelValues(taintOperationWouldRemove, taint).Inc()
+	}
+	logf.Log.WithName("nidhogg").Info("Audited taints not applied", "instance", node.Name, "taints would add", changes.taintsWouldAdd, "taints would remove", changes.taintsWouldRemove)
+
+	// this is a hack to make the event work on a non-namespaced object
+	node.UID = types.UID(node.Name)
+
+	h.recorder.Eventf(node, corev1.EventTypeNormal, "TaintsAudited", "Taints would be added: %s, Taints would be removed: %s", changes.taintsWouldAdd, changes.taintsWouldRemove)
+}
+
+// taintAnnotationKeys returns the node annotations nidhogg keeps for a taint
+func taintAnnotationKeys(taint string) []string {
+	return []string{
+		taint + readyObservedAtAnnotationSuffix,
+		taint + daemonsetReadySinceAnnotationSuffix,
+		taint + daemonsetTaintedSinceAnnotationSuffix,
+		taint + daemonsetBlockedByAnnotationSuffix,
+	}
+}
+
+func validateDaemonsetMode(path *field.Path, mode string) field.ErrorList {
+	switch mode {
+	case "", DaemonsetModeEnforce, DaemonsetModeAudit:
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(path, mode, []string{DaemonsetModeEnforce, DaemonsetModeAudit})}
+}
@@ -0,0 +1,139 @@
+package nidhogg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestHandleNodeAuditsDaemonset(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, nil)
+	pod1 := buildPod("pod1", daemonset1, corev1.PodScheduled)
+	pod2 := buildPod("pod2", daemonset2, corev1.PodScheduled)
+	ds1, ds2 := buildDaemonset(daemonset1), buildDaemonset(daemonset2)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.Daemonsets[1].Mode = DaemonsetModeAudit
+	cfg.BuildSelectors()
+	audited := buildTaintName(namespace, daemonset2)
+	wouldAdd := testutil.ToFloat64(taintOperations.WithLabelValues(taintOperationWouldAdd, audited))
+	wouldRemove := testutil.ToFloat64(taintOperations.WithLabelValues(taintOperationWouldRemove, audited))
+
+	c := newFakeClientBuilder().WithObjects(&node, &pod1, &pod2, &ds1, &ds2).WithStatusSubresource(&corev1.Node{}).Build()
+	recorder := record.NewFakeRecorder(10)
+	handler := NewHandler(c, recorder, cfg)
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}}
+	for i := 0; i < 2; i++ {
+		_, err := handler.HandleNode(ctx, request)
+		assert.NoError(t, err)
+	}
+
+	updatedNode := &corev1.Node{}
+	assert.NoError(t, c.Get(ctx, request.NamespacedName, updatedNode))
+	assert.True(t, hasTaint(updatedNode.Spec.Taints, buildTaintName(namespace, daemonset1)))
+	assert.False(t, hasTaint(updatedNode.Spec.Taints, audited))
+	assert.NotContains(t, updatedNode.Annotations, audited+daemonsetTaintedSinceAnnotationSuffix)
+	// the audited taint is only reported once, it would already be on the node afterwards
+	assert.Equal(t, wouldAdd+1, testutil.ToFloat64(taintOperations.WithLabelValues(taintOperationWouldAdd, audited)))
+	assert.Contains(t, <-recorder.Events, "TaintsAudited")
+
+	pod2.Status.Conditions[0].Type = corev1.PodReady
+	assert.NoError(t, c.Status().Update(ctx, &pod2))
+	_, err := handler.HandleNode(ctx, request)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Get(ctx, request.NamespacedName, updatedNode))
+	assert.True(t, hasTaint(updatedNode.Spec.Taints, buildTaintName(namespace, daemonset1)))
+	assert.NotContains(t, updatedNode.Annotations, audited+daemonsetReadySinceAnnotationSuffix)
+	assert.Equal(t, wouldRemove+1, testutil.ToFloat64(taintOperations.WithLabelValues(taintOperationWouldRemove, audited)))
+}
+
+func TestHandleNodeEnforcedDaemonsetDoesNotWaitForAuditedDependency(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, nil)
+	pod1 := buildPod("pod1", daemonset1, corev1.PodScheduled)
+	pod2 := buildPod("pod2", daemonset2, corev1.PodReady)
+	ds1, ds2 := buildDaemonset(daemonset1), buildDaemonset(daemonset2)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.Daemonsets[0].Mode = DaemonsetModeAudit
+	cfg.Daemonsets[1].DependsOn = []DaemonsetReference{{Name: daemonset1, Namespace: namespace}}
+	cfg.BuildSelectors()
+
+	c := newFakeClientBuilder().WithObjects(&node, &pod1, &pod2, &ds1, &ds2).WithStatusSubresource(&corev1.Node{}).Build()
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}}
+	for i := 0; i < 2; i++ {
+		_, err := handler.HandleNode(ctx, request)
+		assert.NoError(t, err)
+	}
+
+	// the audited taint would be on the node, the enforced daemonset is ready and doesn't wait for it
+	updatedNode := &corev1.Node{}
+	assert.NoError(t, c.Get(ctx, request.NamespacedName, updatedNode))
+	enforced := buildTaintName(namespace, daemonset2)
+	assert.Empty(t, updatedNode.Spec.Taints)
+	assert.NotContains(t, updatedNode.Annotations, enforced+daemonsetBlockedByAnnotationSuffix)
+	assert.Contains(t, updatedNode.Annotations, enforced+daemonsetReadySinceAnnotationSuffix)
+	assert.NotNil(t, handler.audited[nodeName][buildTaintName(namespace, daemonset1)].taint)
+}
+
+func TestHandleNodeDryRunLeavesNodeAlone(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset1, daemonset2})
+	pod1 := buildPod("pod1", daemonset1, corev1.PodReady)
+	ds1 := buildDaemonset(daemonset1)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1})
+	cfg.BuildSelectors()
+
+	c := newFakeClientBuilder().WithObjects(&node, &pod1, &ds1).WithStatusSubresource(&corev1.Node{}).Build()
+	recorder := record.NewFakeRecorder(10)
+	handler := NewHandler(c, recorder, cfg)
+	handler.SetDryRun(true)
+	_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+	assert.NoError(t, err)
+
+	updatedNode := &corev1.Node{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: nodeName}, updatedNode))
+	assert.Equal(t, node.Spec.Taints, updatedNode.Spec.Taints)
+	assert.Empty(t, updatedNode.Annotations)
+	assert.Empty(t, updatedNode.Status.Conditions)
+
+	// the taint of the ready daemonset and the one left by a previous configuration would both be removed
+	event := <-recorder.Events
+	assert.Contains(t, event, "TaintsAudited")
+	assert.Contains(t, event, buildTaintName(namespace, daemonset1))
+	assert.Contains(t, event, buildTaintName(namespace, daemonset2))
+}
+
+func TestAuditedStateIsPruned(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, nil)
+	pod1 := buildPod("pod1", daemonset1, corev1.PodScheduled)
+	pod2 := buildPod("pod2", daemonset2, corev1.PodScheduled)
+	ds1, ds2 := buildDaemonset(daemonset1), buildDaemonset(daemonset2)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.Daemonsets[0].Mode = DaemonsetModeAudit
+	cfg.Daemonsets[1].Mode = DaemonsetModeAudit
+	cfg.BuildSelectors()
+
+	c := newFakeClientBuilder().WithObjects(&node, &pod1, &pod2, &ds1, &ds2).WithStatusSubresource(&corev1.Node{}).Build()
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+	_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+	assert.NoError(t, err)
+	assert.Len(t, handler.audited[nodeName], 2)
+
+	// the first daemonset is enforced from now on
+	cfg.Daemonsets[0].Mode = DaemonsetModeEnforce
+	handler.SetConfig(cfg)
+	assert.Len(t, handler.audited[nodeName], 1)
+	assert.Contains(t, handler.audited[nodeName], buildTaintName(namespace, daemonset2))
+
+	handler.ForgetNode(nodeName)
+	assert.Empty(t, handler.audited)
+}
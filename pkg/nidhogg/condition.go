@@ -0,0 +1,90 @@
+package nidhogg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// NodeConditionReady reports whether nidhogg considers the node ready, i.e. it carries none of the nidhogg taints
+	NodeConditionReady corev1.NodeConditionType = "NidhoggReady"
+
+	conditionReasonReady    = "DaemonsetsReady"
+	conditionReasonNotReady = "DaemonsetsNotReady"
+)
+
+// updateReadyCondition sets the NidhoggReady condition of the node from the daemonsets blocking it, see blockingDaemonsets.
+// A strategic merge patch only carries this condition, leaving the ones maintained by the kubelet alone.
+func (h *Handler) updateReadyCondition(ctx context.Context, node *corev1.Node, blocking []string) error {
+	original := node.DeepCopy()
+	if !setReadyCondition(node, blocking, metav1.Now()) {
+		return nil
+	}
+
+	if err := h.Status().Patch(ctx, node, client.StrategicMergeFrom(original)); err != nil {
+		taintOperationErrors.WithLabelValues("nodeStatusUpdate").Inc()
+		return fmt.Errorf("error updating %s condition: %v", NodeConditionReady, err)
+	}
+	return nil
+}
+
+// blockingDaemonsets returns the namespace/name of the daemonsets whose taint is on the node, the name of the groups
+// whose taint is on the node, or the taint key for taints left over by a previous configuration
+func (h *Handler) blockingDaemonsets(node *corev1.Node) []string {
+	var blocking []string
+	for _, taint := range node.Spec.Taints {
+		if !strings.HasPrefix(taint.Key, h.getTaintNamePrefix()) {
+			continue
+		}
+		name := taint.Key
+		for _, daemonset := range h.config.Daemonsets {
+			if h.getTaintName(daemonset) == taint.Key {
+				name = daemonset.requirementName()
+				break
+			}
+		}
+		blocking = append(blocking, name)
+	}
+	sort.Strings(blocking)
+	return blocking
+}
+
+// setReadyCondition updates the NidhoggReady condition in place and reports whether it changed.
+// The transition time only moves when the status flips.
+func setReadyCondition(node *corev1.Node, blocking []string, now metav1.Time) bool {
+	condition := corev1.NodeCondition{
+		Type:               NodeConditionReady,
+		Status:             corev1.ConditionTrue,
+		Reason:             conditionReasonReady,
+		Message:            "All required daemonsets are ready",
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	if len(blocking) > 0 {
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = conditionReasonNotReady
+		condition.Message = fmt.Sprintf("Waiting for daemonsets: %s", strings.Join(blocking, ", "))
+	}
+
+	for i, existing := range node.Status.Conditions {
+		if existing.Type != NodeConditionReady {
+			continue
+		}
+		if existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+			return false
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		node.Status.Conditions[i] = condition
+		return true
+	}
+	node.Status.Conditions = append(node.Status.Conditions, condition)
+	return true
+}
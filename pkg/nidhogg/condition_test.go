@@ -0,0 +1,68 @@
+package nidhogg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestHandleNodeSetsReadyCondition(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, []string{daemonset})
+	pod := buildPod("pod", daemonset, corev1.PodScheduled)
+	ds := buildDaemonset(daemonset)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+
+	c := newFakeClientBuilder().WithObjects(&node, &pod, &ds).WithStatusSubresource(&corev1.Node{}).Build()
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+	_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+	assert.NoError(t, err)
+
+	condition := getReadyCondition(t, handler, nodeName)
+	assert.Equal(t, corev1.ConditionFalse, condition.Status)
+	assert.Equal(t, conditionReasonNotReady, condition.Reason)
+	assert.Equal(t, "Waiting for daemonsets: namespace/daemonset", condition.Message)
+
+	pod.Status.Conditions[0].Type = corev1.PodReady
+	assert.NoError(t, c.Status().Update(ctx, &pod))
+	_, err = handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+	assert.NoError(t, err)
+
+	condition = getReadyCondition(t, handler, nodeName)
+	assert.Equal(t, corev1.ConditionTrue, condition.Status)
+	assert.Equal(t, conditionReasonReady, condition.Reason)
+}
+
+func TestSetReadyConditionKeepsTransitionTime(t *testing.T) {
+	node := &corev1.Node{}
+	before := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	assert.True(t, setReadyCondition(node, []string{"namespace/daemonset1"}, before))
+	assert.False(t, setReadyCondition(node, []string{"namespace/daemonset1"}, metav1.Now()))
+	assert.True(t, setReadyCondition(node, []string{"namespace/daemonset2"}, metav1.Now()))
+	assert.Equal(t, before, node.Status.Conditions[0].LastTransitionTime)
+
+	assert.True(t, setReadyCondition(node, nil, metav1.Now()))
+	assert.Len(t, node.Status.Conditions, 1)
+	assert.NotEqual(t, before, node.Status.Conditions[0].LastTransitionTime)
+}
+
+func getReadyCondition(t *testing.T, handler *Handler, name string) corev1.NodeCondition {
+	node := &corev1.Node{}
+	assert.NoError(t, handler.Get(context.TODO(), types.NamespacedName{Name: name}, node))
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == NodeConditionReady {
+			return condition
+		}
+	}
+	t.Fatalf("node %s has no %s condition", name, NodeConditionReady)
+	return corev1.NodeCondition{}
+}
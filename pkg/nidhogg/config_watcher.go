@@ -0,0 +1,104 @@
+package nidhogg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ConfigWatcher reloads the handler configuration when the config file changes or the process receives SIGHUP
+type ConfigWatcher struct {
+	path    string
+	handler *Handler
+	// pod receives an event for each reload, none is emitted when its name is empty
+	pod    types.NamespacedName
+	hangup chan os.Signal
+}
+
+// NewConfigWatcher constructs a new instance of ConfigWatcher, reloads are reported as events on the given nidhogg pod.
+// SIGHUP is handled from now on, so that it doesn't kill the process before the watcher is started.
+func NewConfigWatcher(path string, h *Handler, pod types.NamespacedName) *ConfigWatcher {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	return &ConfigWatcher{path: path, handler: h, pod: pod, hangup: hangup}
+}
+
+// NeedLeaderElection keeps the configuration of standby replicas up to date
+func (w *ConfigWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start watches the config file until the context is done.
+// The directory is watched rather than the file, as ConfigMap volumes are updated by swapping a symlink.
+func (w *ConfigWatcher) Start(ctx context.Context) error {
+	log := logf.Log.WithName("config-watcher")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to create config file watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(w.path)); err != nil {
+		return fmt.Errorf("unable to watch config file: %v", err)
+	}
+
+	defer signal.Stop(w.hangup)
+
+	log.Info("Watching config file", "path", w.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.hangup:
+			w.Reload("SIGHUP")
+		case evt, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if evt.Op == fsnotify.Chmod {
+				continue
+			}
+			w.Reload("file changed")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error(err, "Error watching config file")
+		}
+	}
+}
+
+// Reload reads the config file and swaps it into the handler, the current configuration is kept when the file is invalid
+func (w *ConfigWatcher) Reload(trigger string) {
+	log := logf.Log.WithName("config-watcher")
+
+	conf, err := GetConfig(w.path)
+	if err != nil {
+		log.Error(err, "Rejecting new config, keeping the current one", "trigger", trigger)
+		return
+	}
+
+	oldHash := w.handler.Config().Hash()
+	newHash := conf.Hash()
+	if oldHash == newHash {
+		return
+	}
+
+	w.handler.SetConfig(conf)
+	log.Info("Config reloaded", "trigger", trigger, "oldHash", oldHash, "newHash", newHash)
+
+	if w.pod.Name != "" {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: w.pod.Name, Namespace: w.pod.Namespace}}
+		w.handler.recorder.Eventf(pod, corev1.EventTypeNormal, "ConfigReloaded", "Config reloaded on %s, hash %s replaced by %s", trigger, oldHash, newHash)
+	}
+}
@@ -0,0 +1,67 @@
+package nidhogg
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReloadSwapsValidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "daemonsets:\n  - name: daemonset1\n    namespace: namespace\n")
+	cfg, err := GetConfig(path)
+	assert.NoError(t, err)
+
+	handler := NewHandler(fake.NewClientBuilder().Build(), record.NewFakeRecorder(0), cfg)
+	writeConfig(t, path, "daemonsets:\n  - name: daemonset2\n    namespace: namespace\n")
+	recorder := record.NewFakeRecorder(1)
+	handler.recorder = recorder
+	NewConfigWatcher(path, handler, types.NamespacedName{Name: "nidhogg-0", Namespace: "nidhogg"}).Reload("test")
+
+	assert.Equal(t, daemonset2, handler.Config().Daemonsets[0].Name)
+	assert.Len(t, handler.ConfigChanges(), 1)
+	event := <-recorder.Events
+	assert.Contains(t, event, "ConfigReloaded")
+	assert.Contains(t, event, cfg.Hash())
+}
+
+func TestReloadKeepsConfigWhenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "daemonsets:\n  - name: daemonset1\n    namespace: namespace\n")
+	cfg, err := GetConfig(path)
+	assert.NoError(t, err)
+
+	handler := NewHandler(fake.NewClientBuilder().Build(), record.NewFakeRecorder(0), cfg)
+	writeConfig(t, path, "nodeSelector:\n  - \"role in (\"\ndaemonsets:\n  - name: daemonset2\n    namespace: namespace\n")
+	NewConfigWatcher(path, handler, types.NamespacedName{}).Reload("test")
+
+	assert.Equal(t, daemonset1, handler.Config().Daemonsets[0].Name)
+	assert.Empty(t, handler.ConfigChanges())
+}
+
+func TestNewConfigWatcherHandlesSIGHUP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	handler := NewHandler(fake.NewClientBuilder().Build(), record.NewFakeRecorder(0), HandlerConfig{})
+	w := NewConfigWatcher(path, handler, types.NamespacedName{})
+	defer signal.Stop(w.hangup)
+
+	// the process survives a SIGHUP received before the watcher is started
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	select {
+	case <-w.hangup:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGHUP wasn't handled")
+	}
+}
+
+func writeConfig(t *testing.T, path string, content string) {
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
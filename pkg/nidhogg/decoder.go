@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/uswitch/nidhogg/pkg/apis/nidhogg/v1alpha1"
 	yaml "gopkg.in/yaml.v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // GetConfig reads the config file, parses it whether it be in json or yaml and returns a handler config
@@ -21,10 +23,88 @@ func GetConfig(config string) (HandlerConfig, error) {
 		return HandlerConfig{}, fmt.Errorf("error parsing file: %v", err)
 	}
 
+	if errs := handlerConf.Validate(nil); len(errs) > 0 {
+		return HandlerConfig{}, fmt.Errorf("invalid config file: %v", errs.ToAggregate())
+	}
+
+	if err := handlerConf.BuildSelectors(); err != nil {
+		return HandlerConfig{}, err
+	}
+
+	return handlerConf, nil
+
+}
+
+// ConfigFromPolicy converts the spec of a NidhoggPolicy into a handler config
+func ConfigFromPolicy(policy *v1alpha1.NidhoggPolicy) (HandlerConfig, error) {
+
+	if errs := ValidatePolicy(policy); len(errs) > 0 {
+		return HandlerConfig{}, errs.ToAggregate()
+	}
+
+	handlerConf := policyConfig(policy)
 	if err := handlerConf.BuildSelectors(); err != nil {
 		return HandlerConfig{}, err
 	}
 
 	return handlerConf, nil
+}
+
+// ValidatePolicy reports the fields of a NidhoggPolicy spec which would make it unusable as a handler config
+func ValidatePolicy(policy *v1alpha1.NidhoggPolicy) field.ErrorList {
+	return policyConfig(policy).Validate(field.NewPath("spec"))
+}
 
+func policyConfig(policy *v1alpha1.NidhoggPolicy) HandlerConfig {
+	handlerConf := HandlerConfig{
+		TaintNamePrefix:            policy.Spec.TaintNamePrefix,
+		TaintEffect:                policy.Spec.TaintEffect,
+		TaintRemovalDelayInSeconds: policy.Spec.TaintRemovalDelayInSeconds,
+		NodeSelector:               policy.Spec.NodeSelector,
+		ReadySincePolicy:           policy.Spec.ReadySincePolicy,
+		StartupOnly:                policy.Spec.StartupOnly,
+		RequireCurrentRevision:     policy.Spec.RequireCurrentRevision,
+		Discovery: DaemonsetDiscovery{
+			Enabled:           policy.Spec.Discovery.Enabled,
+			NamespaceSelector: policy.Spec.Discovery.NamespaceSelector,
+		},
+	}
+	for _, group := range policy.Spec.Groups {
+		handlerConf.Groups = append(handlerConf.Groups, DaemonsetGroup{Name: group.Name, AnyOf: group.AnyOf, MinReady: group.MinReady})
+	}
+	for _, daemonset := range policy.Spec.Daemonsets {
+		handlerConf.Daemonsets = append(handlerConf.Daemonsets, Daemonset{
+			Name:                       daemonset.Name,
+			Namespace:                  daemonset.Namespace,
+			TaintEffect:                daemonset.TaintEffect,
+			TaintRemovalDelayInSeconds: daemonset.TaintRemovalDelayInSeconds,
+			NodeSelector:               daemonset.NodeSelector,
+			StartupOnly:                daemonset.StartupOnly,
+			Readiness:                  policyReadiness(daemonset.Readiness),
+			RequireCurrentRevision:     daemonset.RequireCurrentRevision,
+			DependsOn:                  policyDependencies(daemonset.DependsOn),
+			Group:                      daemonset.Group,
+			Mode:                       daemonset.Mode,
+		})
+	}
+	return handlerConf
+}
+
+func policyReadiness(readiness *v1alpha1.Readiness) *Readiness {
+	if readiness == nil {
+		return nil
+	}
+	return &Readiness{
+		Mode:       readiness.Mode,
+		Containers: readiness.Containers,
+		Conditions: readiness.Conditions,
+	}
+}
+
+func policyDependencies(references []v1alpha1.DaemonsetReference) []DaemonsetReference {
+	var dependencies []DaemonsetReference
+	for _, reference := range references {
+		dependencies = append(dependencies, DaemonsetReference{Name: reference.Name, Namespace: reference.Namespace})
+	}
+	return dependencies
 }
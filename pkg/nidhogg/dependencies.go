@@ -0,0 +1,169 @@
+package nidhogg
+
+import (
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// a taint was kept or added because the daemonsets it depends on aren't ready yet, see dependsOn
+	taintOperationBlocked = "blocked"
+	// appended to a taint name, lists the dependencies the daemonset is waiting for
+	daemonsetBlockedByAnnotationSuffix = ".blocked-by"
+)
+
+// DaemonsetReference names another daemonset of the configuration
+type DaemonsetReference struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+}
+
+func (r DaemonsetReference) key() types.NamespacedName {
+	return types.NamespacedName{Namespace: r.Namespace, Name: r.Name}
+}
+
+// orderedRequirements returns the requirements with each one after those it depends on, keeping the configured order otherwise.
+// Cycles are rejected when the configuration is validated.
+func (hc HandlerConfig) orderedRequirements() []requirement {
+	requirements := hc.requirements()
+	edges := requirementEdges(requirements)
+
+	visited := make([]bool, len(requirements))
+	ordered := make([]requirement, 0, len(requirements))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		for _, edge := range edges[i] {
+			visit(edge.to)
+		}
+		ordered = append(ordered, requirements[i])
+	}
+	for i := range requirements {
+		visit(i)
+	}
+	return ordered
+}
+
+// requirementEdge links a requirement to one it depends on, through the dependsOn of one of its daemonsets
+type requirementEdge struct {
+	to int
+	// daemonset is the position in the configuration of the daemonset depending on the other requirement
+	daemonset int
+}
+
+// requirementEdges returns, for each requirement, the requirements its daemonsets depend on.
+// Dependencies which aren't configured are left out.
+func requirementEdges(requirements []requirement) [][]requirementEdge {
+	index := make(map[types.NamespacedName]int)
+	for i, requirement := range requirements {
+		for _, daemonset := range requirement.daemonsets {
+			index[daemonset.key()] = i
+		}
+	}
+	edges := make([][]requirementEdge, len(requirements))
+	for i, requirement := range requirements {
+		for n, daemonset := range requirement.daemonsets {
+			for _, dependency := range daemonset.DependsOn {
+				if j, ok := index[dependency.key()]; ok {
+					edges[i] = append(edges[i], requirementEdge{to: j, daemonset: requirement.indexes[n]})
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// unmetDependencies returns the dependencies of the daemonsets required on the node which aren't clear of their taint yet.
+// The ignored dependencies are never waited for.
+func unmetDependencies(daemonsets []Daemonset, required, cleared, ignored map[types.NamespacedName]bool) []string {
+	var unmet []string
+	for _, daemonset := range daemonsets {
+		for _, dependency := range daemonset.DependsOn {
+			name := dependency.key().String()
+			if required[dependency.key()] && !cleared[dependency.key()] && !ignored[dependency.key()] && !slices.Contains(unmet, name) {
+				unmet = append(unmet, name)
+			}
+		}
+	}
+	return unmet
+}
+
+// markCleared records that the taint of the daemonsets is gone from the node
+func markCleared(cleared map[types.NamespacedName]bool, daemonsets []Daemonset) {
+	for _, daemonset := range daemonsets {
+		cleared[daemonset.key()] = true
+	}
+}
+
+// setBlockedBy records the unmet dependencies of the daemonset on the node and reports whether it just became blocked
+func setBlockedBy(node *corev1.Node, taint string, unmet []string) bool {
+	key := taint + daemonsetBlockedByAnnotationSuffix
+	_, wasBlocked := node.Annotations[key]
+	if len(unmet) == 0 {
+		delete(node.Annotations, key)
+		return false
+	}
+	setAnnotation(node, key, strings.Join(unmet, ","))
+	return !wasBlocked
+}
+
+// validateDependencies checks that the daemonsets only depend on other configured daemonsets, without cycles.
+// A daemonset can't depend on a daemonset of its own group either, as they share the same taint.
+func validateDependencies(path *field.Path, daemonsets []Daemonset) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	configured := make(map[types.NamespacedName]bool, len(daemonsets))
+	for _, daemonset := range daemonsets {
+		configured[daemonset.key()] = true
+	}
+	for i, daemonset := range daemonsets {
+		for j, dependency := range daemonset.DependsOn {
+			if !configured[dependency.key()] {
+				allErrs = append(allErrs, field.NotFound(path.Index(i).Child("dependsOn").Index(j), dependency.key().String()))
+			}
+		}
+	}
+
+	requirements := groupRequirements(daemonsets)
+	edges := requirementEdges(requirements)
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(requirements))
+	var stack []int
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, edge := range edges[i] {
+			switch state[edge.to] {
+			case unvisited:
+				visit(edge.to)
+			case visiting:
+				var cycle []string
+				for _, k := range stack[slices.Index(stack, edge.to):] {
+					cycle = append(cycle, requirements[k].name)
+				}
+				cycle = append(cycle, requirements[edge.to].name)
+				allErrs = append(allErrs, field.Invalid(path.Index(edge.daemonset).Child("dependsOn"), strings.Join(cycle, " -> "), "dependency cycle"))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+	}
+	for i := range requirements {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,71 @@
+package nidhogg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestOrderedRequirementsPutsDependenciesFirst(t *testing.T) {
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2, daemonset})
+	cfg.Daemonsets[0].DependsOn = []DaemonsetReference{{Name: daemonset, Namespace: namespace}}
+
+	var names []string
+	for _, requirement := range cfg.orderedRequirements() {
+		names = append(names, requirement.name)
+	}
+	assert.Equal(t, []string{namespace + "/" + daemonset, namespace + "/" + daemonset1, namespace + "/" + daemonset2}, names)
+}
+
+func TestCalculateTaintsBlocksDaemonsetUntilDependenciesAreReady(t *testing.T) {
+	ctx := context.TODO()
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.Daemonsets[1].DependsOn = []DaemonsetReference{{Name: daemonset1, Namespace: namespace}}
+	cfg.BuildSelectors()
+	daemonsets := []appsv1.DaemonSet{buildDaemonset(daemonset1), buildDaemonset(daemonset2)}
+	blockedBy := buildTaintName(namespace, daemonset2) + daemonsetBlockedByAnnotationSuffix
+
+	node := buildNode(namespace, []string{daemonset1, daemonset2})
+	handler := buildHandler([]corev1.Pod{buildPod("pod1", daemonset1, corev1.PodScheduled), buildPod("pod2", daemonset2, corev1.PodReady)}, daemonsets, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Contains(t, updatedNode.Spec.Taints, buildActiveTaint(namespace, daemonset1))
+	assert.Contains(t, updatedNode.Spec.Taints, buildActiveTaint(namespace, daemonset2))
+	assert.Empty(t, changes.taintsRemoved)
+	assert.Equal(t, []string{buildTaintName(namespace, daemonset2)}, changes.taintsBlocked)
+	assert.Equal(t, namespace+"/"+daemonset1, updatedNode.Annotations[blockedBy])
+
+	handler = buildHandler([]corev1.Pod{buildPod("pod1", daemonset1, corev1.PodReady), buildPod("pod2", daemonset2, corev1.PodReady)}, daemonsets, cfg)
+	updatedNode, changes, err = handler.calculateTaints(ctx, updatedNode)
+
+	assert.NoError(t, err)
+	assert.Empty(t, updatedNode.Spec.Taints)
+	assert.ElementsMatch(t, []string{buildTaintName(namespace, daemonset1), buildTaintName(namespace, daemonset2)}, changes.taintsRemoved)
+	assert.Empty(t, changes.taintsBlocked)
+	assert.NotContains(t, updatedNode.Annotations, blockedBy)
+}
+
+func TestValidateDependencies(t *testing.T) {
+	path := field.NewPath("daemonsets")
+	daemonsets := buildDaemonsets(namespace, []string{daemonset1, daemonset2, daemonset})
+	daemonsets[0].DependsOn = []DaemonsetReference{{Name: daemonset2, Namespace: namespace}}
+
+	assert.Empty(t, validateDependencies(path, daemonsets))
+
+	daemonsets[1].DependsOn = []DaemonsetReference{{Name: daemonset1, Namespace: namespace}}
+	daemonsets[2].DependsOn = []DaemonsetReference{{Name: "missing", Namespace: namespace}}
+	errs := validateDependencies(path, daemonsets)
+
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, field.ErrorTypeNotFound, errs[0].Type)
+		assert.Equal(t, "daemonsets[2].dependsOn[0]", errs[0].Field)
+		assert.Equal(t, field.ErrorTypeInvalid, errs[1].Type)
+		assert.Equal(t, "daemonsets[1].dependsOn", errs[1].Field)
+		assert.Equal(t, namespace+"/"+daemonset1+" -> "+namespace+"/"+daemonset2+" -> "+namespace+"/"+daemonset1, errs[1].BadValue)
+	}
+}
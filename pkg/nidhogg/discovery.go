@@ -0,0 +1,257 @@
+package nidhogg
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// RequiredAnnotation set to "true" on a daemonset makes nodes wait for it when discovery is enabled
+	RequiredAnnotation = defaultTaintKeyPrefix + "/required"
+	// TaintEffectAnnotation overrides the taint effect for a discovered daemonset
+	TaintEffectAnnotation = defaultTaintKeyPrefix + "/taint-effect"
+	// TaintRemovalDelayAnnotation overrides the taint removal delay in seconds for a discovered daemonset
+	TaintRemovalDelayAnnotation = defaultTaintKeyPrefix + "/taint-removal-delay-seconds"
+	// NodeSelectorAnnotation overrides the node selector for a discovered daemonset
+	NodeSelectorAnnotation = defaultTaintKeyPrefix + "/node-selector"
+	// StartupOnlyAnnotation overrides the startup only mode for a discovered daemonset
+	StartupOnlyAnnotation = defaultTaintKeyPrefix + "/startup-only"
+	// ReadinessAnnotation sets the readiness mode of a discovered daemonset
+	ReadinessAnnotation = defaultTaintKeyPrefix + "/readiness"
+	// ReadyContainersAnnotation lists, comma separated, the containers waited for with the Containers readiness mode
+	ReadyContainersAnnotation = defaultTaintKeyPrefix + "/ready-containers"
+	// ReadyConditionsAnnotation lists, comma separated, the pod conditions waited for with the Conditions readiness mode
+	ReadyConditionsAnnotation = defaultTaintKeyPrefix + "/ready-conditions"
+	// RequireCurrentRevisionAnnotation overrides the current revision requirement for a discovered daemonset
+	RequireCurrentRevisionAnnotation = defaultTaintKeyPrefix + "/require-current-revision"
+	// GroupAnnotation adds a discovered daemonset to a group, whose taint waits for all of its daemonsets
+	GroupAnnotation = defaultTaintKeyPrefix + "/group"
+	// ModeAnnotation set to Audit only reports the taint changes for a discovered daemonset
+	ModeAnnotation = defaultTaintKeyPrefix + "/mode"
+)
+
+// DaemonsetDiscovery tells whether daemonsets can opt in with annotations, and from which namespaces
+type DaemonsetDiscovery struct {
+	Enabled           bool     `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	NamespaceSelector []string `json:"namespaceSelector,omitempty" yaml:"namespaceSelector,omitempty"`
+}
+
+// discoveredDaemonset is a daemonset carrying the required annotation, along with the labels of its namespace
+type discoveredDaemonset struct {
+	Daemonset
+	namespaceLabels labels.Set
+	// invalid holds why the annotations of the daemonset can't be used, it is left out while set
+	invalid string
+}
+
+// DiscoverDaemonset records whether the daemonset opted in with the required annotation, along with the settings read from its
+// other annotations. Every node is reconciled again when this changes the daemonsets nidhogg waits for.
+// Nothing is recorded while discovery is disabled, the daemonsets are discovered again when it changes, see DiscoveryChanges.
+func (h *Handler) DiscoverDaemonset(ctx context.Context, ds *appsv1.DaemonSet) {
+	key := types.NamespacedName{Namespace: ds.Namespace, Name: ds.Name}
+	h.mu.RLock()
+	conf := h.static
+	_, known := h.discovered[key]
+	h.mu.RUnlock()
+
+	if !conf.Discovery.Enabled {
+		return
+	}
+	if ds.Annotations[RequiredAnnotation] != "true" {
+		if known {
+			h.setDiscovered(key, nil)
+		}
+		return
+	}
+
+	// the labels of the namespace are only needed to match the namespace selector
+	var namespaceLabels labels.Set
+	if len(conf.Discovery.NamespaceSelector) > 0 {
+		namespace := &corev1.Namespace{}
+		if err := h.Get(ctx, types.NamespacedName{Name: ds.Namespace}, namespace); err != nil {
+			logf.Log.Error(err, "Could not fetch namespace of discovered daemonset, keeping its previous state", "daemonset", ds.Name, "namespace", ds.Namespace)
+			return
+		}
+		namespaceLabels = labels.Set(namespace.Labels)
+	}
+
+	daemonset, errs := daemonsetFromAnnotations(ds)
+	if len(errs) == 0 {
+		errs = conf.validateDaemonset(nil, daemonset)
+	}
+	discovered := &discoveredDaemonset{Daemonset: daemonset, namespaceLabels: namespaceLabels}
+	if len(errs) > 0 {
+		discovered.invalid = errs.ToAggregate().Error()
+	}
+
+	if h.setDiscovered(key, discovered) && discovered.invalid != "" {
+		h.recorder.Eventf(ds, corev1.EventTypeWarning, "InvalidAnnotations", "Daemonset is not required by nidhogg: %s", discovered.invalid)
+	}
+}
+
+// ForgetDaemonset drops the daemonset from the discovered ones once it is deleted
+func (h *Handler) ForgetDaemonset(key types.NamespacedName) {
+	h.setDiscovered(key, nil)
+}
+
+// setDiscovered stores the discovered daemonset, or removes it when nil, and returns whether it changed.
+// The write lock, which waits for the reconciles in progress, is only taken when the daemonset changed.
+func (h *Handler) setDiscovered(key types.NamespacedName, discovered *discoveredDaemonset) bool {
+	h.mu.RLock()
+	unchanged := h.discoveredUnchanged(key, discovered)
+	h.mu.RUnlock()
+	if unchanged {
+		return false
+	}
+
+	h.mu.Lock()
+	// the configuration may have changed while no lock was held
+	if h.discoveredUnchanged(key, discovered) || !h.static.Discovery.Enabled {
+		h.mu.Unlock()
+		return false
+	}
+
+	if discovered == nil {
+		delete(h.discovered, key)
+	} else {
+		if h.discovered == nil {
+			h.discovered = make(map[types.NamespacedName]discoveredDaemonset)
+		}
+		h.discovered[key] = *discovered
+	}
+	conf := h.mergeDiscovered()
+	changed := !reflect.DeepEqual(conf.Daemonsets, h.config.Daemonsets)
+	h.config = conf
+	if changed {
+		h.pruneAuditedState()
+	}
+	h.mu.Unlock()
+
+	if changed {
+		logf.Log.Info("Discovered daemonsets changed", "daemonset", key.Name, "namespace", key.Namespace, "required", discovered != nil && discovered.invalid == "")
+		h.notifyConfigChange(conf)
+	}
+	return true
+}
+
+// discoveredUnchanged tells whether the daemonset is already stored as discovered, the caller must hold the lock
+func (h *Handler) discoveredUnchanged(key types.NamespacedName, discovered *discoveredDaemonset) bool {
+	previous, ok := h.discovered[key]
+	return discovered == nil && !ok || discovered != nil && ok && reflect.DeepEqual(previous, *discovered)
+}
+
+// mergeDiscovered returns the static configuration with the discovered daemonsets added when discovery is enabled,
+// a daemonset listed in the configuration keeps its configured settings. The caller must hold the write lock.
+func (h *Handler) mergeDiscovered() HandlerConfig {
+	conf := h.static
+	if !conf.Discovery.Enabled || len(h.discovered) == 0 {
+		return conf
+	}
+
+	namespaceSelector := labels.Everything()
+	if len(conf.Discovery.NamespaceSelector) > 0 {
+		// validated along with the rest of the configuration
+		namespaceSelector, _ = parseSelector(conf.Discovery.NamespaceSelector)
+	}
+
+	keys := make([]types.NamespacedName, 0, len(h.discovered))
+	for key := range h.discovered {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b types.NamespacedName) int {
+		return strings.Compare(a.String(), b.String())
+	})
+
+	conf.Daemonsets = slices.Clone(conf.Daemonsets)
+	for _, key := range keys {
+		discovered := h.discovered[key]
+		if discovered.invalid != "" || !namespaceSelector.Matches(discovered.namespaceLabels) {
+			continue
+		}
+		if slices.ContainsFunc(conf.Daemonsets, func(daemonset Daemonset) bool { return daemonset.key() == key }) {
+			continue
+		}
+		daemonsets := append(slices.Clone(conf.Daemonsets), discovered.Daemonset)
+		if errs := validateGroups(field.NewPath("daemonsets"), daemonsets); len(errs) > 0 {
+			logf.Log.Info("Ignoring discovered daemonset not matching the other daemonsets of its group", "daemonset", key.Name, "namespace", key.Namespace, "group", discovered.Group, "error", errs.ToAggregate().Error())
+			continue
+		}
+		conf.Daemonsets = daemonsets
+	}
+
+	if err := conf.BuildSelectors(); err != nil {
+		logf.Log.Error(err, "Ignoring discovered daemonsets")
+		return h.static
+	}
+	return conf
+}
+
+// daemonsetFromAnnotations reads the settings of a discovered daemonset from its annotations
+func daemonsetFromAnnotations(ds *appsv1.DaemonSet) (Daemonset, field.ErrorList) {
+	path := field.NewPath("metadata", "annotations")
+	allErrs := field.ErrorList{}
+	daemonset := Daemonset{
+		Name:        ds.Name,
+		Namespace:   ds.Namespace,
+		TaintEffect: ds.Annotations[TaintEffectAnnotation],
+		Group:       ds.Annotations[GroupAnnotation],
+		Mode:        ds.Annotations[ModeAnnotation],
+	}
+
+	if value, ok := ds.Annotations[TaintRemovalDelayAnnotation]; ok {
+		delay, err := strconv.Atoi(value)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Key(TaintRemovalDelayAnnotation), value, "must be a number of seconds"))
+		}
+		daemonset.TaintRemovalDelayInSeconds = &delay
+	}
+	if value, ok := ds.Annotations[NodeSelectorAnnotation]; ok {
+		daemonset.NodeSelector = []string{value}
+	}
+	if value, ok := ds.Annotations[StartupOnlyAnnotation]; ok {
+		startupOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Key(StartupOnlyAnnotation), value, "must be true or false"))
+		}
+		daemonset.StartupOnly = &startupOnly
+	}
+	if value, ok := ds.Annotations[RequireCurrentRevisionAnnotation]; ok {
+		requireCurrentRevision, err := strconv.ParseBool(value)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Key(RequireCurrentRevisionAnnotation), value, "must be true or false"))
+		}
+		daemonset.RequireCurrentRevision = &requireCurrentRevision
+	}
+	mode, hasMode := ds.Annotations[ReadinessAnnotation]
+	containers, hasContainers := ds.Annotations[ReadyContainersAnnotation]
+	conditions, hasConditions := ds.Annotations[ReadyConditionsAnnotation]
+	if hasMode || hasContainers || hasConditions {
+		daemonset.Readiness = &Readiness{
+			Mode:       mode,
+			Containers: splitList(containers),
+			Conditions: splitList(conditions),
+		}
+	}
+	return daemonset, allErrs
+}
+
+// splitList splits a comma separated annotation value, ignoring blank entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
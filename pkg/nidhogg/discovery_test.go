@@ -0,0 +1,125 @@
+package nidhogg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestDiscoverDaemonsetMergesWithStaticList(t *testing.T) {
+	ctx := context.TODO()
+	c := newFakeClientBuilder().WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"nidhogg": "enabled"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+	).Build()
+	cfg := HandlerConfig{
+		Daemonsets: []Daemonset{{Name: "static", Namespace: "team-a"}},
+		Discovery:  DaemonsetDiscovery{Enabled: true, NamespaceSelector: []string{"nidhogg=enabled"}},
+	}
+	assert.NoError(t, cfg.BuildSelectors())
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+
+	discovered := buildAnnotatedDaemonset("team-a", "discovered", map[string]string{
+		RequiredAnnotation:          "true",
+		TaintEffectAnnotation:       "NoExecute",
+		TaintRemovalDelayAnnotation: "30",
+		NodeSelectorAnnotation:      "pool=gpu",
+	})
+	handler.DiscoverDaemonset(ctx, discovered)
+	handler.DiscoverDaemonset(ctx, buildAnnotatedDaemonset("team-a", "static", map[string]string{RequiredAnnotation: "true", TaintEffectAnnotation: "NoExecute"}))
+	handler.DiscoverDaemonset(ctx, buildAnnotatedDaemonset("team-b", "other", map[string]string{RequiredAnnotation: "true"}))
+	handler.DiscoverDaemonset(ctx, buildAnnotatedDaemonset("team-a", "opted-out", map[string]string{RequiredAnnotation: "false"}))
+
+	delay := 30
+	assert.Equal(t, []Daemonset{
+		{Name: "static", Namespace: "team-a"},
+		{Name: "discovered", Namespace: "team-a", TaintEffect: "NoExecute", TaintRemovalDelayInSeconds: &delay, NodeSelector: []string{"pool=gpu"}},
+	}, handler.config.Daemonsets)
+	assert.NotNil(t, handler.config.DaemonsetSelectors[types.NamespacedName{Namespace: "team-a", Name: "discovered"}])
+	assert.Len(t, handler.Config().Daemonsets, 1)
+	assert.Len(t, handler.ConfigChanges(), 1)
+
+	handler.ForgetDaemonset(types.NamespacedName{Namespace: "team-a", Name: "discovered"})
+	assert.Equal(t, []Daemonset{{Name: "static", Namespace: "team-a"}}, handler.config.Daemonsets)
+}
+
+func TestDiscoverDaemonsetRequiresDiscoveryEnabled(t *testing.T) {
+	ctx := context.TODO()
+	c := newFakeClientBuilder().WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}).Build()
+	handler := NewHandler(c, record.NewFakeRecorder(10), HandlerConfig{})
+
+	handler.DiscoverDaemonset(ctx, buildAnnotatedDaemonset(namespace, daemonset, map[string]string{RequiredAnnotation: "true"}))
+	assert.Empty(t, handler.config.Daemonsets)
+	assert.Empty(t, handler.ConfigChanges())
+
+	// the daemonsets are discovered again once discovery is enabled
+	handler.SetConfig(HandlerConfig{Discovery: DaemonsetDiscovery{Enabled: true}})
+	assert.Len(t, handler.DiscoveryChanges(), 1)
+	handler.DiscoverDaemonset(ctx, buildAnnotatedDaemonset(namespace, daemonset, map[string]string{RequiredAnnotation: "true"}))
+	assert.Equal(t, []Daemonset{{Name: daemonset, Namespace: namespace}}, handler.config.Daemonsets)
+
+	handler.SetConfig(HandlerConfig{})
+	assert.Empty(t, handler.config.Daemonsets)
+	assert.Empty(t, handler.discovered)
+}
+
+func TestDiscoverDaemonsetSkipsUnchangedDaemonsets(t *testing.T) {
+	ctx := context.TODO()
+	// the namespace isn't fetched without a namespace selector
+	handler := NewHandler(newFakeClientBuilder().Build(), record.NewFakeRecorder(10), HandlerConfig{Discovery: DaemonsetDiscovery{Enabled: true}})
+
+	handler.DiscoverDaemonset(ctx, buildAnnotatedDaemonset(namespace, "other", nil))
+	assert.Empty(t, handler.ConfigChanges())
+
+	ds := buildAnnotatedDaemonset(namespace, daemonset, map[string]string{RequiredAnnotation: "true"})
+	handler.DiscoverDaemonset(ctx, ds)
+	assert.Equal(t, []Daemonset{{Name: daemonset, Namespace: namespace}}, handler.config.Daemonsets)
+	<-handler.ConfigChanges()
+
+	handler.DiscoverDaemonset(ctx, ds)
+	assert.Empty(t, handler.ConfigChanges())
+}
+
+func TestDiscoverDaemonsetWithInvalidAnnotations(t *testing.T) {
+	ctx := context.TODO()
+	c := newFakeClientBuilder().WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}).Build()
+	recorder := record.NewFakeRecorder(10)
+	handler := NewHandler(c, recorder, HandlerConfig{Discovery: DaemonsetDiscovery{Enabled: true}})
+
+	ds := buildAnnotatedDaemonset(namespace, daemonset, map[string]string{RequiredAnnotation: "true", TaintRemovalDelayAnnotation: "soon"})
+	handler.DiscoverDaemonset(ctx, ds)
+	handler.DiscoverDaemonset(ctx, ds)
+
+	assert.Empty(t, handler.config.Daemonsets)
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "InvalidAnnotations")
+}
+
+func buildAnnotatedDaemonset(namespace, name string, annotations map[string]string) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Annotations: annotations}}
+}
+
+func TestDiscoverDaemonsetJoinsGroup(t *testing.T) {
+	ctx := context.TODO()
+	c := newFakeClientBuilder().WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}).Build()
+	cfg := HandlerConfig{
+		Daemonsets: []Daemonset{{Name: "cni", Namespace: namespace, Group: "bootstrap"}},
+		Discovery:  DaemonsetDiscovery{Enabled: true},
+	}
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+
+	handler.DiscoverDaemonset(ctx, buildAnnotatedDaemonset(namespace, "agent", map[string]string{RequiredAnnotation: "true", GroupAnnotation: "bootstrap"}))
+	// the taint of the group can't have two effects
+	handler.DiscoverDaemonset(ctx, buildAnnotatedDaemonset(namespace, "proxy", map[string]string{RequiredAnnotation: "true", GroupAnnotation: "bootstrap", TaintEffectAnnotation: "NoExecute"}))
+
+	assert.Equal(t, []Daemonset{
+		{Name: "cni", Namespace: namespace, Group: "bootstrap"},
+		{Name: "agent", Namespace: namespace, Group: "bootstrap"},
+	}, handler.config.Daemonsets)
+}
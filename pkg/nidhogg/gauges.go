@@ -0,0 +1,91 @@
+package nidhogg
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/wait"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// gaugeRefreshPeriod is how often the node gauges are recomputed from the cache
+const gaugeRefreshPeriod = 30 * time.Second
+
+var (
+	taintedNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tainted_nodes",
+		Help: "Number of nodes currently carrying the taint of a daemonset",
+	},
+		[]string{
+			"namespace",
+			"daemonset",
+		},
+	)
+	matchingNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "matching_nodes",
+		Help: "Number of nodes a daemonset is required on",
+	},
+		[]string{
+			"namespace",
+			"daemonset",
+		},
+	)
+	fullyReadyNodes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "fully_ready_nodes",
+		Help: "Number of nodes required by at least one daemonset and carrying none of the nidhogg taints",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		taintedNodes,
+		matchingNodes,
+		fullyReadyNodes,
+	)
+}
+
+// GaugeUpdater periodically recomputes the node gauges from the cache, so they don't depend on which
+// reconciles the current process happened to run
+type GaugeUpdater struct {
+	handler *Handler
+}
+
+// NewGaugeUpdater constructs a new instance of GaugeUpdater
+func NewGaugeUpdater(h *Handler) *GaugeUpdater {
+	return &GaugeUpdater{handler: h}
+}
+
+// NeedLeaderElection makes only the leader report the gauges, so they aren't counted once per replica
+func (u *GaugeUpdater) NeedLeaderElection() bool {
+	return true
+}
+
+// Start updates the gauges until the context is done
+func (u *GaugeUpdater) Start(ctx context.Context) error {
+	log := logf.Log.WithName("gauge-updater")
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := u.Update(ctx); err != nil {
+			log.Error(err, "Unable to update node gauges")
+		}
+	}, gaugeRefreshPeriod)
+	return nil
+}
+
+// Update recomputes the gauges, dropping the series of daemonsets which are no longer configured
+func (u *GaugeUpdater) Update(ctx context.Context) error {
+	counts, ready, err := u.handler.nodeCounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	taintedNodes.Reset()
+	matchingNodes.Reset()
+	for _, count := range counts {
+		taintedNodes.WithLabelValues(count.Namespace, count.Name).Set(float64(count.Tainted))
+		matchingNodes.WithLabelValues(count.Namespace, count.Name).Set(float64(count.Matching))
+	}
+	fullyReadyNodes.Set(float64(ready))
+	return nil
+}
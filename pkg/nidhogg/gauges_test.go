@@ -0,0 +1,31 @@
+package nidhogg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGaugeUpdaterUpdate(t *testing.T) {
+	ctx := context.TODO()
+	taintedNode := buildNode(namespace, []string{daemonset1})
+	readyNode := buildNodeWithoutTaints(namespace, nil)
+	readyNode.Name = "ready-node"
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.BuildSelectors()
+
+	handler := buildHandler(nil, nil, cfg)
+	assert.NoError(t, handler.Create(ctx, &taintedNode))
+	assert.NoError(t, handler.Create(ctx, &readyNode))
+	taintedNodes.WithLabelValues(namespace, "removed-daemonset").Set(3)
+
+	assert.NoError(t, NewGaugeUpdater(&handler).Update(ctx))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(taintedNodes.WithLabelValues(namespace, daemonset1)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(taintedNodes.WithLabelValues(namespace, daemonset2)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(matchingNodes.WithLabelValues(namespace, daemonset1)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(fullyReadyNodes))
+	assert.Equal(t, 4, testutil.CollectAndCount(taintedNodes)+testutil.CollectAndCount(matchingNodes))
+}
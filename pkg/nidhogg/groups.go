@@ -0,0 +1,147 @@
+package nidhogg
+
+import (
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// DaemonsetGroup tells how many daemonsets of a group must be ready on a node before the taint of the group is removed,
+// all of them unless set
+type DaemonsetGroup struct {
+	Name string `json:"name" yaml:"name"`
+	// AnyOf is met by a single ready daemonset, like MinReady set to 1
+	AnyOf    bool `json:"anyOf,omitempty" yaml:"anyOf,omitempty"`
+	MinReady int  `json:"minReady,omitempty" yaml:"minReady,omitempty"`
+}
+
+// requirement is what a nidhogg taint waits for: a single daemonset, or the daemonsets of a group
+type requirement struct {
+	// name is the group, or the namespace/name of a daemonset outside of any group
+	name       string
+	daemonsets []Daemonset
+	// indexes are the positions of the daemonsets in the configuration
+	indexes []int
+	// minReady is the number of daemonsets of the group which must be ready, all of them when 0
+	minReady int
+}
+
+// requirements returns the requirements of the configured daemonsets, with the settings of their group
+func (hc HandlerConfig) requirements() []requirement {
+	requirements := groupRequirements(hc.Daemonsets)
+	for i := range requirements {
+		for _, group := range hc.Groups {
+			if group.Name != requirements[i].daemonsets[0].Group {
+				continue
+			}
+			requirements[i].minReady = group.MinReady
+			if group.AnyOf {
+				requirements[i].minReady = 1
+			}
+		}
+	}
+	return requirements
+}
+
+// readyNeeded returns how many of the daemonsets required on a node must be ready, a group never waits for
+// more daemonsets than the node requires
+func (r requirement) readyNeeded(required int) int {
+	if r.minReady == 0 {
+		return required
+	}
+	return min(r.minReady, required)
+}
+
+// groupRequirements gathers the daemonsets sharing a taint, in the order the groups and daemonsets are first configured
+func groupRequirements(daemonsets []Daemonset) []requirement {
+	var requirements []requirement
+	groups := make(map[string]int)
+	for i, daemonset := range daemonsets {
+		if daemonset.Group != "" {
+			if j, ok := groups[daemonset.Group]; ok {
+				requirements[j].daemonsets = append(requirements[j].daemonsets, daemonset)
+				requirements[j].indexes = append(requirements[j].indexes, i)
+				continue
+			}
+			groups[daemonset.Group] = len(requirements)
+		}
+		requirements = append(requirements, requirement{
+			name:       daemonset.requirementName(),
+			daemonsets: []Daemonset{daemonset},
+			indexes:    []int{i},
+		})
+	}
+	return requirements
+}
+
+// requirementName names the requirement the daemonset belongs to
+func (d Daemonset) requirementName() string {
+	if d.Group != "" {
+		return d.Group
+	}
+	return d.key().String()
+}
+
+// validateGroups checks that the daemonsets of a group agree on the settings of the taint they share
+func validateGroups(path *field.Path, daemonsets []Daemonset) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for _, requirement := range groupRequirements(daemonsets) {
+		first := requirement.daemonsets[0]
+		for n, daemonset := range requirement.daemonsets[1:] {
+			dsPath := path.Index(requirement.indexes[n+1])
+			msg := fmt.Sprintf("must match the other daemonsets of group %s", daemonset.Group)
+			if daemonset.TaintEffect != first.TaintEffect {
+				allErrs = append(allErrs, field.Invalid(dsPath.Child("taintEffect"), daemonset.TaintEffect, msg))
+			}
+			if !reflect.DeepEqual(daemonset.TaintRemovalDelayInSeconds, first.TaintRemovalDelayInSeconds) {
+				allErrs = append(allErrs, field.Invalid(dsPath.Child("taintRemovalDelayInSeconds"), daemonset.TaintRemovalDelayInSeconds, msg))
+			}
+			if !reflect.DeepEqual(daemonset.StartupOnly, first.StartupOnly) {
+				allErrs = append(allErrs, field.Invalid(dsPath.Child("startupOnly"), daemonset.StartupOnly, msg))
+			}
+			if daemonset.Mode != first.Mode {
+				allErrs = append(allErrs, field.Invalid(dsPath.Child("mode"), daemonset.Mode, msg))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateGroupSettings checks the settings of the groups, which may also apply to discovered daemonsets
+func validateGroupSettings(path *field.Path, groups []DaemonsetGroup) field.ErrorList {
+	allErrs := field.ErrorList{}
+	seen := make(map[string]bool)
+	for i, group := range groups {
+		groupPath := path.Index(i)
+		if group.Name == "" {
+			allErrs = append(allErrs, field.Required(groupPath.Child("name"), ""))
+		}
+		allErrs = append(allErrs, validateGroupName(groupPath.Child("name"), group.Name)...)
+		if seen[group.Name] {
+			allErrs = append(allErrs, field.Duplicate(groupPath.Child("name"), group.Name))
+		}
+		seen[group.Name] = true
+
+		if group.MinReady < 0 {
+			allErrs = append(allErrs, field.Invalid(groupPath.Child("minReady"), group.MinReady, "must not be negative"))
+		}
+		if group.AnyOf && group.MinReady != 0 {
+			allErrs = append(allErrs, field.Invalid(groupPath.Child("minReady"), group.MinReady, "can't be set along with anyOf"))
+		}
+	}
+	return allErrs
+}
+
+// validateGroupName checks the group of a daemonset, it can't contain dots so that its taint never clashes with the taint of a daemonset
+func validateGroupName(path *field.Path, group string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if group == "" {
+		return allErrs
+	}
+	for _, msg := range validation.IsDNS1123Label(group) {
+		allErrs = append(allErrs, field.Invalid(path, group, msg))
+	}
+	return allErrs
+}
@@ -0,0 +1,125 @@
+package nidhogg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestCalculateTaintsWaitsForEveryDaemonsetOfGroup(t *testing.T) {
+	ctx := context.TODO()
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.Daemonsets[0].Group = "bootstrap"
+	cfg.Daemonsets[1].Group = "bootstrap"
+	cfg.BuildSelectors()
+	daemonsets := []appsv1.DaemonSet{buildDaemonset(daemonset1), buildDaemonset(daemonset2)}
+	groupTaint := taintNamePrefix + "/bootstrap"
+
+	node := buildNodeWithoutTaints(namespace, nil)
+	handler := buildHandler([]corev1.Pod{buildPod("pod1", daemonset1, corev1.PodReady), buildPod("pod2", daemonset2, corev1.PodScheduled)}, daemonsets, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{groupTaint}, changes.taintsAdded)
+	assert.Len(t, updatedNode.Spec.Taints, 1)
+	assert.True(t, hasTaint(updatedNode.Spec.Taints, groupTaint))
+
+	handler = buildHandler([]corev1.Pod{buildPod("pod1", daemonset1, corev1.PodReady), buildPod("pod2", daemonset2, corev1.PodReady)}, daemonsets, cfg)
+	updatedNode, changes, err = handler.calculateTaints(ctx, updatedNode)
+
+	assert.NoError(t, err)
+	assert.Empty(t, updatedNode.Spec.Taints)
+	assert.Equal(t, []string{groupTaint}, changes.taintsRemoved)
+}
+
+func TestCalculateTaintsWithAnyOfGroup(t *testing.T) {
+	ctx := context.TODO()
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.Daemonsets[0].Group = "agent"
+	cfg.Daemonsets[1].Group = "agent"
+	cfg.Groups = []DaemonsetGroup{{Name: "agent", AnyOf: true}}
+	cfg.BuildSelectors()
+	daemonsets := []appsv1.DaemonSet{buildDaemonset(daemonset1), buildDaemonset(daemonset2)}
+	groupTaint := taintNamePrefix + "/agent"
+
+	node := buildNodeWithoutTaints(namespace, nil)
+	handler := buildHandler([]corev1.Pod{buildPod("pod1", daemonset1, corev1.PodScheduled), buildPod("pod2", daemonset2, corev1.PodScheduled)}, daemonsets, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{groupTaint}, changes.taintsAdded)
+
+	handler = buildHandler([]corev1.Pod{buildPod("pod1", daemonset1, corev1.PodScheduled), buildPod("pod2", daemonset2, corev1.PodReady)}, daemonsets, cfg)
+	updatedNode, changes, err = handler.calculateTaints(ctx, updatedNode)
+
+	assert.NoError(t, err)
+	assert.Empty(t, updatedNode.Spec.Taints)
+	assert.Equal(t, []string{groupTaint}, changes.taintsRemoved)
+}
+
+func TestRequirementReadyNeeded(t *testing.T) {
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2, daemonset})
+	for i := range cfg.Daemonsets {
+		cfg.Daemonsets[i].Group = "agent"
+	}
+
+	assert.Equal(t, 3, cfg.requirements()[0].readyNeeded(3))
+	cfg.Groups = []DaemonsetGroup{{Name: "agent", MinReady: 2}}
+	assert.Equal(t, 2, cfg.requirements()[0].readyNeeded(3))
+	// only one of the daemonsets is required on the node, such as architecture specific variants
+	assert.Equal(t, 1, cfg.requirements()[0].readyNeeded(1))
+	cfg.Groups = []DaemonsetGroup{{Name: "agent", AnyOf: true}}
+	assert.Equal(t, 1, cfg.requirements()[0].readyNeeded(3))
+}
+
+func TestTolerationsOfGroups(t *testing.T) {
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2, daemonset})
+	cfg.Daemonsets[0].Group = "bootstrap"
+	cfg.Daemonsets[1].Group = "bootstrap"
+	handler := NewHandler(newFakeClientBuilder().Build(), nil, cfg)
+
+	keys := func(tolerations []corev1.Toleration) []string {
+		var keys []string
+		for _, toleration := range tolerations {
+			keys = append(keys, toleration.Key)
+		}
+		return keys
+	}
+	assert.Equal(t, []string{taintNamePrefix + "/bootstrap", buildTaintName(namespace, daemonset)}, keys(handler.Tolerations(nil, nil)))
+	assert.Equal(t, []string{taintNamePrefix + "/bootstrap"}, keys(handler.Tolerations(nil, []string{"bootstrap"})))
+	assert.Equal(t, []string{taintNamePrefix + "/bootstrap"}, keys(handler.Tolerations([]types.NamespacedName{{Namespace: namespace, Name: daemonset2}}, nil)))
+}
+
+func TestValidateGroups(t *testing.T) {
+	delay := 10
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2, daemonset})
+	for i := range cfg.Daemonsets {
+		cfg.Daemonsets[i].Group = "bootstrap"
+	}
+	assert.Empty(t, cfg.Validate(nil))
+
+	cfg.Daemonsets[1].TaintEffect = "NoExecute"
+	cfg.Daemonsets[2].TaintRemovalDelayInSeconds = &delay
+	cfg.Daemonsets[2].DependsOn = []DaemonsetReference{{Name: daemonset1, Namespace: namespace}}
+	cfg.Daemonsets = append(cfg.Daemonsets, Daemonset{Name: "other", Namespace: namespace, Group: "kube-system.bootstrap"})
+	cfg.Groups = []DaemonsetGroup{{Name: "bootstrap", AnyOf: true, MinReady: 2}, {Name: "bootstrap", MinReady: -1}}
+
+	var fields []string
+	for _, err := range cfg.Validate(field.NewPath("spec")) {
+		fields = append(fields, err.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"spec.daemonsets[1].taintEffect",
+		"spec.daemonsets[2].taintRemovalDelayInSeconds",
+		"spec.daemonsets[2].dependsOn",
+		"spec.daemonsets[3].group",
+		"spec.groups[0].minReady",
+		"spec.groups[1].name",
+		"spec.groups[1].minReady",
+	}, fields)
+}
@@ -2,9 +2,13 @@ package nidhogg
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/uswitch/nidhogg/pkg/utils"
@@ -16,17 +20,33 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
-	defaultTaintKeyPrefix      = "nidhogg.uswitch.com"
-	taintOperationAdded        = "added"
-	taintOperationRemoved      = "removed"
+	defaultTaintKeyPrefix = "nidhogg.uswitch.com"
+	taintOperationAdded   = "added"
+	taintOperationRemoved = "removed"
+	// a taint wasn't added back because the node already started, see startupOnly
+	taintOperationSuppressed   = "suppressed"
 	readySinceAnnotationSuffix = "/ready-since"
+	// records when the node was first fully ready whatever the ready-since policy, so that its bootstrap is only observed once
+	firstReadyAnnotationSuffix = "/first-ready"
+	// appended to a taint name, records when the daemonset pod was first seen ready while the taint removal is delayed
+	readyObservedAtAnnotationSuffix = ".ready-observed-at"
+	// appended to a taint name, record when the taint of the daemonset was last removed and added
+	daemonsetReadySinceAnnotationSuffix   = ".ready-since"
+	daemonsetTaintedSinceAnnotationSuffix = ".tainted-since"
+
+	// ReadySinceFirstReady keeps the ready-since annotation of a node once set, the default
+	ReadySinceFirstReady = "FirstReady"
+	// ReadySinceLastReady clears the ready-since annotation when the node is tainted again
+	ReadySinceLastReady = "LastReady"
 )
 
 var (
@@ -47,156 +67,606 @@ var (
 			"operation",
 		},
 	)
+	// nodes usually take from tens of seconds to tens of minutes to bootstrap
+	nodeReadyDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "node_ready_duration_seconds",
+		Help:    "Time from the creation of a node to the removal of its last nidhogg taint",
+		Buckets: prometheus.ExponentialBuckets(10, 2, 10),
+	})
+	daemonsetTaintRemovalDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "daemonset_taint_removal_duration_seconds",
+		Help:    "Time from the creation of a node to the removal of the taint of a daemonset, until the node is first fully ready",
+		Buckets: prometheus.ExponentialBuckets(10, 2, 10),
+	},
+		[]string{
+			"namespace",
+			"daemonset",
+		},
+	)
 )
 
 func init() {
 	metrics.Registry.MustRegister(
 		taintOperations,
 		taintOperationErrors,
+		nodeReadyDuration,
+		daemonsetTaintRemovalDuration,
 	)
 }
 
 // Handler performs the main business logic of the Wave controller
 type Handler struct {
 	client.Client
-	recorder record.EventRecorder
-	config   HandlerConfig
+	// apiReader reads the nodes again after a conflict, the cache likely still holds the outdated node
+	apiReader client.Reader
+	recorder  record.EventRecorder
+	// mu guards the configuration, reconciles share the read lock and only configuration changes take the write lock
+	mu sync.RWMutex
+	// static is the configuration as set, config adds the discovered daemonsets to it
+	static        HandlerConfig
+	config        HandlerConfig
+	discovered    map[types.NamespacedName]discoveredDaemonset
+	configChanges chan event.TypedGenericEvent[HandlerConfig]
+	// discoveryChanges gets the discovery settings when they change, the daemonsets must be discovered again
+	discoveryChanges chan event.TypedGenericEvent[DaemonsetDiscovery]
+	// watchedPlacements holds the placements of the daemonsets as last seen by the informer,
+	// a deleted daemonset has a nil placement so its nodes don't wait for it
+	placementsMu      sync.Mutex
+	watchedPlacements map[types.NamespacedName]*DaemonsetPlacement
+	// dryRun audits every taint, see SetDryRun
+	dryRun bool
+	// audited holds, per node, the audited taints as they would be if they were enforced. It is only kept in memory,
+	// after a restart the audited taints start again from the state of the nodes.
+	auditMu sync.Mutex
+	audited map[string]map[string]auditedTaint
 }
 
 // HandlerConfig contains the options for Nidhogg
 type HandlerConfig struct {
-	TaintNamePrefix            string      `json:"taintNamePrefix,omitempty" yaml:"taintNamePrefix,omitempty"`
-	TaintEffect                string      `json:"taintEffect,omitempty" yaml:"taintEffect,omitempty"`
-	TaintRemovalDelayInSeconds int         `json:"taintRemovalDelayInSeconds,omitempty" yaml:"taintRemovalDelayInSeconds,omitempty"`
-	Daemonsets                 []Daemonset `json:"daemonsets" yaml:"daemonsets"`
-	NodeSelector               []string    `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
-	DaemonsetSelectors         map[Daemonset]labels.Selector
+	TaintNamePrefix            string                                   `json:"taintNamePrefix,omitempty" yaml:"taintNamePrefix,omitempty"`
+	TaintEffect                string                                   `json:"taintEffect,omitempty" yaml:"taintEffect,omitempty"`
+	TaintRemovalDelayInSeconds int                                      `json:"taintRemovalDelayInSeconds,omitempty" yaml:"taintRemovalDelayInSeconds,omitempty"`
+	Daemonsets                 []Daemonset                              `json:"daemonsets" yaml:"daemonsets"`
+	NodeSelector               []string                                 `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
+	ReadySincePolicy           string                                   `json:"readySincePolicy,omitempty" yaml:"readySincePolicy,omitempty"`
+	StartupOnly                bool                                     `json:"startupOnly,omitempty" yaml:"startupOnly,omitempty"`
+	RequireCurrentRevision     bool                                     `json:"requireCurrentRevision,omitempty" yaml:"requireCurrentRevision,omitempty"`
+	Discovery                  DaemonsetDiscovery                       `json:"discovery,omitempty" yaml:"discovery,omitempty"`
+	Groups                     []DaemonsetGroup                         `json:"groups,omitempty" yaml:"groups,omitempty"`
+	DaemonsetSelectors         map[types.NamespacedName]labels.Selector `json:"-" yaml:"-"`
+}
+
+// Hash returns a short digest of the configuration, identifying it in logs
+func (hc HandlerConfig) Hash() string {
+	raw, _ := json.Marshal(hc)
+	return fmt.Sprintf("%x", sha256.Sum256(raw))[:12]
 }
 
 func (hc *HandlerConfig) BuildSelectors() error {
-	hc.DaemonsetSelectors = make(map[Daemonset]labels.Selector)
-	globalSelector := labels.Nothing()
-	for _, rawSelector := range hc.NodeSelector {
-		if selector, err := labels.Parse(rawSelector); err != nil {
-			return fmt.Errorf("error parsing selector: %v", err)
-		} else {
-			requirements, _ := selector.Requirements()
-			globalSelector = labels.NewSelector().Add(requirements...)
-		}
+	hc.DaemonsetSelectors = make(map[types.NamespacedName]labels.Selector)
+	globalSelector, err := parseSelector(hc.NodeSelector)
+	if err != nil {
+		return err
 	}
-	//Will initialize all daemonsets with the same selector, either representing the NodeSelector config or labels.Nothing if no config was provided for NodeSelector
+	//Will initialize all daemonsets with their own NodeSelector, falling back to the global one, or labels.Nothing if no config was provided for NodeSelector
 	for _, daemonset := range hc.Daemonsets {
-		hc.DaemonsetSelectors[daemonset] = globalSelector
+		selector := globalSelector
+		if daemonset.NodeSelector != nil {
+			if selector, err = parseSelector(daemonset.NodeSelector); err != nil {
+				return fmt.Errorf("daemonset %s in namespace %s: %v", daemonset.Name, daemonset.Namespace, err)
+			}
+		}
+		hc.DaemonsetSelectors[daemonset.key()] = selector
 	}
 	return nil
 }
 
-// Daemonset contains the name and namespace of a Daemonset
+// parseSelector combines the raw selectors into a single one, matching nothing when none is given
+func parseSelector(rawSelectors []string) (labels.Selector, error) {
+	if len(rawSelectors) == 0 {
+		return labels.Nothing(), nil
+	}
+	combined := labels.NewSelector()
+	for _, rawSelector := range rawSelectors {
+		selector, err := labels.Parse(rawSelector)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing selector: %v", err)
+		}
+		requirements, _ := selector.Requirements()
+		combined = combined.Add(requirements...)
+	}
+	return combined, nil
+}
+
+// Daemonset contains the name and namespace of a Daemonset, along with optional overrides of the global taint settings
 type Daemonset struct {
-	Name      string `json:"name" yaml:"name"`
-	Namespace string `json:"namespace" yaml:"namespace"`
+	Name                       string               `json:"name" yaml:"name"`
+	Namespace                  string               `json:"namespace" yaml:"namespace"`
+	TaintEffect                string               `json:"taintEffect,omitempty" yaml:"taintEffect,omitempty"`
+	TaintRemovalDelayInSeconds *int                 `json:"taintRemovalDelayInSeconds,omitempty" yaml:"taintRemovalDelayInSeconds,omitempty"`
+	NodeSelector               []string             `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
+	StartupOnly                *bool                `json:"startupOnly,omitempty" yaml:"startupOnly,omitempty"`
+	Readiness                  *Readiness           `json:"readiness,omitempty" yaml:"readiness,omitempty"`
+	RequireCurrentRevision     *bool                `json:"requireCurrentRevision,omitempty" yaml:"requireCurrentRevision,omitempty"`
+	DependsOn                  []DaemonsetReference `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	Group                      string               `json:"group,omitempty" yaml:"group,omitempty"`
+	Mode                       string               `json:"mode,omitempty" yaml:"mode,omitempty"`
+}
+
+func (d Daemonset) key() types.NamespacedName {
+	return types.NamespacedName{Namespace: d.Namespace, Name: d.Name}
 }
 
 type taintChanges struct {
 	taintsAdded   []string
 	taintsRemoved []string
+	// taintsDelayed are waiting for their removal delay, the node must be reconciled again after requeueAfter
+	taintsDelayed []string
+	requeueAfter  time.Duration
+	// taintsSuppressed would have been added if their daemonsets weren't startupOnly
+	taintsSuppressed []string
+	// taintsBlocked just started waiting for the daemonsets they depend on
+	taintsBlocked []string
+	// taintsWouldAdd and taintsWouldRemove are audited, they would have been added or removed if they were enforced
+	taintsWouldAdd    []string
+	taintsWouldRemove []string
+}
+
+// delay records a taint waiting for its removal delay, the node is requeued for the first one to elapse
+func (c *taintChanges) delay(taint string, remaining time.Duration) {
+	c.taintsDelayed = append(c.taintsDelayed, taint)
+	if c.requeueAfter == 0 || remaining < c.requeueAfter {
+		c.requeueAfter = remaining
+	}
+}
+
+// DaemonsetNodeCount holds the number of nodes a daemonset is required on and how many of them are still tainted
+type DaemonsetNodeCount struct {
+	Daemonset
+	Matching int
+	Tainted  int
 }
 
 // NewHandler constructs a new instance of Handler
 func NewHandler(c client.Client, r record.EventRecorder, conf HandlerConfig) *Handler {
-	return &Handler{Client: c, recorder: r, config: conf}
+	return &Handler{
+		Client:           c,
+		apiReader:        c,
+		recorder:         r,
+		static:           conf,
+		config:           conf,
+		configChanges:    make(chan event.TypedGenericEvent[HandlerConfig], 1),
+		discoveryChanges: make(chan event.TypedGenericEvent[DaemonsetDiscovery], 1),
+	}
+}
+
+// SetAPIReader sets the reader used to read a node again when patching it conflicted, usually the uncached reader of the manager.
+// It must be called before nodes are reconciled.
+func (h *Handler) SetAPIReader(reader client.Reader) {
+	h.apiReader = reader
+}
+
+// Config returns the configuration given to the handler, without the discovered daemonsets
+func (h *Handler) Config() HandlerConfig {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.static
+}
+
+// SetConfig replaces the handler configuration and notifies ConfigChanges so every node gets reconciled again
+func (h *Handler) SetConfig(conf HandlerConfig) {
+	h.mu.Lock()
+	discoveryChanged := !reflect.DeepEqual(h.static.Discovery, conf.Discovery)
+	if !conf.Discovery.Enabled {
+		h.discovered = nil
+	}
+	h.static = conf
+	h.config = h.mergeDiscovered()
+	h.pruneAuditedState()
+	conf = h.config
+	h.mu.Unlock()
+
+	h.notifyConfigChange(conf)
+	if discoveryChanged {
+		select {
+		case h.discoveryChanges <- event.TypedGenericEvent[DaemonsetDiscovery]{Object: conf.Discovery}:
+		default:
+		}
+	}
+}
+
+func (h *Handler) notifyConfigChange(conf HandlerConfig) {
+	// a pending notification already covers this change, the config is read when the nodes are reconciled
+	select {
+	case h.configChanges <- event.TypedGenericEvent[HandlerConfig]{Object: conf}:
+	default:
+	}
+}
+
+// TaintNamePrefix returns the prefix of the taints and annotations managed by the handler
+func (h *Handler) TaintNamePrefix() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.getTaintNamePrefix()
+}
+
+// ConfigChanges returns a channel receiving an event each time the configuration is replaced
+func (h *Handler) ConfigChanges() <-chan event.TypedGenericEvent[HandlerConfig] {
+	return h.configChanges
+}
+
+// DiscoveryChanges returns a channel receiving an event each time the discovery settings change
+func (h *Handler) DiscoveryChanges() <-chan event.TypedGenericEvent[DaemonsetDiscovery] {
+	return h.discoveryChanges
+}
+
+// Tolerations returns tolerations for the taints of the given daemonsets and groups, or of every configured daemonset when none
+// is given. A daemonset of a group gets the taint of its group tolerated. Daemonsets and groups which aren't configured are ignored.
+func (h *Handler) Tolerations(daemonsets []types.NamespacedName, groups []string) []corev1.Toleration {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var tolerations []corev1.Toleration
+	for _, daemonset := range h.config.Daemonsets {
+		if (len(daemonsets) > 0 || len(groups) > 0) && !slices.Contains(daemonsets, daemonset.key()) && (daemonset.Group == "" || !slices.Contains(groups, daemonset.Group)) {
+			continue
+		}
+		taint := h.getTaintName(daemonset)
+		if slices.ContainsFunc(tolerations, func(toleration corev1.Toleration) bool { return toleration.Key == taint }) {
+			continue
+		}
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:      taint,
+			Operator: corev1.TolerationOpExists,
+			Effect:   h.getTaintEffect(daemonset),
+		})
+	}
+	return tolerations
+}
+
+// nodeUpdate is the state of a node worked out from the configuration, along with the settings it was worked out with.
+// It is computed under the read lock, which is released before calling the API server.
+type nodeUpdate struct {
+	node         *corev1.Node
+	changes      taintChanges
+	auditedState map[string]auditedTaint
+	taintLess    bool
+	// blocking are the daemonsets reported by the NidhoggReady condition
+	blocking      []string
+	dryRun        bool
+	readySinceKey string
+	firstReadyKey string
 }
 
 // HandleNode works out what taints need to be applied to the nodeName
 func (h *Handler) HandleNode(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	log := logf.Log.WithName("nidhogg")
 
-	// Fetch the Node instance
-	latestNode := &corev1.Node{}
-	err := h.Get(ctx, request.NamespacedName, latestNode)
+	var latestNode *corev1.Node
+	var update nodeUpdate
+
+	// the patch only applies to the resourceVersion it was computed from,
+	// on conflict the node is read again and its taints computed from the fresh state
+	var reader client.Reader = h.Client
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		// Fetch the Node instance
+		latestNode = &corev1.Node{}
+		err := reader.Get(ctx, request.NamespacedName, latestNode)
+		if err != nil {
+			return err
+		}
+		// the cache is rarely up to date right after a conflict
+		reader = h.apiReader
+
+		update, err = h.updateNode(ctx, latestNode)
+		if err != nil {
+			return err
+		}
+		updatedNode := update.node
+		if update.dryRun || reflect.DeepEqual(updatedNode, latestNode) {
+			return nil
+		}
+
+		log.Info("Updating Node taints", "instance", updatedNode.Name, "taints added", update.changes.taintsAdded, "taints removed", update.changes.taintsRemoved, "taints delayed", update.changes.taintsDelayed, "taints blocked", update.changes.taintsBlocked, "taintLess", update.taintLess, "readySinceValue", updatedNode.Annotations[update.readySinceKey])
+
+		// a merge patch only carries the taints and annotations computed here, along with the resourceVersion precondition
+		err = h.Patch(ctx, updatedNode, client.MergeFromWithOptions(latestNode, client.MergeFromWithOptimisticLock{}))
+		if errors.IsConflict(err) {
+			taintOperationErrors.WithLabelValues("nodeConflict").Inc()
+		} else if err != nil {
+			taintOperationErrors.WithLabelValues("nodeUpdate").Inc()
+		}
+		return err
+	})
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
+			h.storeAuditedState(request.Name, nil)
 			return reconcile.Result{}, nil
 		}
-		// Error reading the object - requeue the request.
+		if errors.IsConflict(err) {
+			log.Info("Node kept changing while patching it, will retry", "instance", request.Name)
+		}
+		// Error reading or patching the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	updatedNode, taintChanges, err := h.calculateTaints(ctx, latestNode)
+	updatedNode, changes := update.node, update.changes
+	h.storeAuditedState(request.Name, update.auditedState)
+
+	// on a copy, the status patch must not show up when comparing with latestNode below
+	if !update.dryRun {
+		if err := h.updateReadyCondition(ctx, updatedNode.DeepCopy(), update.blocking); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if len(changes.taintsSuppressed) > 0 {
+		h.reportSuppressedTaints(updatedNode.DeepCopy(), changes.taintsSuppressed)
+	}
+	if len(changes.taintsBlocked) > 0 {
+		h.reportBlockedTaints(updatedNode.DeepCopy(), changes.taintsBlocked)
+	}
+	if len(changes.taintsWouldAdd) > 0 || len(changes.taintsWouldRemove) > 0 {
+		h.reportAuditedTaints(updatedNode.DeepCopy(), changes)
+	}
+
+	// nothing is patched when running dry
+	if update.dryRun || reflect.DeepEqual(updatedNode, latestNode) {
+		return reconcile.Result{RequeueAfter: changes.requeueAfter}, nil
+	}
+	log.Info("Node taints updated.")
+
+	for _, taintAdded := range changes.taintsAdded {
+		taintOperations.WithLabelValues(taintOperationAdded, taintAdded).Inc()
+	}
+	for _, taintRemoved := range changes.taintsRemoved {
+		taintOperations.WithLabelValues(taintOperationRemoved, taintRemoved).Inc()
+	}
+
+	// nodes ready before the first-ready annotation was introduced only have the ready-since one
+	_, wasReady := latestNode.Annotations[update.readySinceKey]
+	if _, firstReady := latestNode.Annotations[update.firstReadyKey]; firstReady {
+		wasReady = true
+	}
+	if !wasReady {
+		h.observeReadyDurations(updatedNode, changes.taintsRemoved, update.taintLess)
+	}
+
+	// only annotations bookkeeping changed, there is nothing to report
+	if len(changes.taintsAdded) == 0 && len(changes.taintsRemoved) == 0 && (wasReady || !update.taintLess) {
+		return reconcile.Result{RequeueAfter: changes.requeueAfter}, nil
+	}
+
+	// this is a hack to make the event work on a non-namespaced object
+	updatedNode.UID = types.UID(updatedNode.Name)
+
+	h.recorder.Eventf(updatedNode, corev1.EventTypeNormal, "TaintsChanged", "Taints added: %s, Taints removed: %s, TaintLess: %v, FirstTimeReady: %q", changes.taintsAdded, changes.taintsRemoved, update.taintLess, updatedNode.Annotations[update.readySinceKey])
+
+	return reconcile.Result{RequeueAfter: changes.requeueAfter}, nil
+}
+
+// updateNode works out the taints and annotations of the node from the current configuration. It holds the read lock,
+// the node is read and patched without it so that configuration changes and the webhooks don't wait for the API server.
+func (h *Handler) updateNode(ctx context.Context, latestNode *corev1.Node) (nodeUpdate, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	update := nodeUpdate{
+		dryRun:        h.dryRun,
+		readySinceKey: h.getTaintNamePrefix() + readySinceAnnotationSuffix,
+		firstReadyKey: h.getTaintNamePrefix() + firstReadyAnnotationSuffix,
+	}
+
+	// audited taints are worked out from the state they would have if they were enforced, then left as they are on the node
+	audited := h.auditedTaints(latestNode)
+	updatedNode, changes, err := h.calculateTaints(ctx, h.withAuditedState(latestNode, audited))
 	if err != nil {
 		taintOperationErrors.WithLabelValues("calculateTaints").Inc()
-		return reconcile.Result{}, fmt.Errorf("error calculating taints for nodeName: %v", err)
+		return update, fmt.Errorf("error calculating taints for nodeName: %v", err)
 	}
+	update.auditedState = revertAuditedTaints(latestNode, updatedNode, &changes, audited)
 
-	taintLess := true
+	update.taintLess = true
 	for _, taint := range updatedNode.Spec.Taints {
 		if strings.HasPrefix(taint.Key, h.getTaintNamePrefix()) {
-			taintLess = false
+			update.taintLess = false
+		}
+	}
+
+	// the node is left as it is when running dry
+	if !h.dryRun && update.taintLess {
+		if _, ok := updatedNode.Annotations[update.readySinceKey]; !ok {
+			setAnnotation(updatedNode, update.readySinceKey, time.Now().UTC().Format(time.RFC3339))
+		}
+		if _, ok := updatedNode.Annotations[update.firstReadyKey]; !ok {
+			setAnnotation(updatedNode, update.firstReadyKey, updatedNode.Annotations[update.readySinceKey])
 		}
+	} else if !h.dryRun && h.config.ReadySincePolicy == ReadySinceLastReady {
+		delete(updatedNode.Annotations, update.readySinceKey)
 	}
 
-	var readySinceKey = h.getTaintNamePrefix() + readySinceAnnotationSuffix
-	var readySinceValue string
-	if taintLess {
-		readySinceValue = time.Now().Format("2006-01-02T15:04:05Z")
-		if updatedNode.Annotations == nil {
-			updatedNode.Annotations = map[string]string{
-				readySinceKey: readySinceValue,
+	update.node = updatedNode
+	update.changes = changes
+	update.blocking = h.blockingDaemonsets(updatedNode)
+	return update, nil
+}
+
+// reportSuppressedTaints tells about the daemonsets which are not ready on a node that isn't tainted again because of startupOnly
+func (h *Handler) reportSuppressedTaints(node *corev1.Node, taintsSuppressed []string) {
+	for _, taint := range taintsSuppressed {
+		taintOperations.WithLabelValues(taintOperationSuppressed, taint).Inc()
+	}
+
+	// this is a hack to make the event work on a non-namespaced object
+	node.UID = types.UID(node.Name)
+
+	h.recorder.Eventf(node, corev1.EventTypeWarning, "DaemonsetsNotReady", "Taints not added back as the node already started: %s", taintsSuppressed)
+}
+
+// reportBlockedTaints tells about the taints which just started waiting for the daemonsets they depend on
+func (h *Handler) reportBlockedTaints(node *corev1.Node, taintsBlocked []string) {
+	waiting := make([]string, 0, len(taintsBlocked))
+	for _, taint := range taintsBlocked {
+		taintOperations.WithLabelValues(taintOperationBlocked, taint).Inc()
+		waiting = append(waiting, fmt.Sprintf("%s waits for %s", taint, node.Annotations[taint+daemonsetBlockedByAnnotationSuffix]))
+	}
+
+	// this is a hack to make the event work on a non-namespaced object
+	node.UID = types.UID(node.Name)
+
+	h.recorder.Eventf(node, corev1.EventTypeNormal, "DaemonsetsBlocked", "Taints kept until the daemonsets they depend on are ready: %s", strings.Join(waiting, ", "))
+}
+
+// observeReadyDurations records how long the node took to get rid of the removed taints since its creation.
+// It is only called until the node is first fully ready, later re-taints would skew the bootstrap latency.
+func (h *Handler) observeReadyDurations(node *corev1.Node, taintsRemoved []string, taintLess bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	elapsed := time.Since(node.CreationTimestamp.Time).Seconds()
+	for _, taint := range taintsRemoved {
+		for _, daemonset := range h.config.Daemonsets {
+			if h.getTaintName(daemonset) == taint {
+				daemonsetTaintRemovalDuration.WithLabelValues(daemonset.Namespace, daemonset.Name).Observe(elapsed)
 			}
-		} else if _, ok := updatedNode.Annotations[readySinceKey]; !ok {
-			updatedNode.Annotations[readySinceKey] = readySinceValue
-		} else {
-			readySinceValue = updatedNode.Annotations[readySinceKey]
 		}
-	} else if updatedNode.Annotations != nil {
-		readySinceValue = updatedNode.Annotations[readySinceKey]
 	}
+	// nodes which never carried a taint, such as those already there when nidhogg is installed, aren't bootstrapping
+	if taintLess && len(taintsRemoved) > 0 {
+		nodeReadyDuration.Observe(elapsed)
+	}
+}
 
-	if !reflect.DeepEqual(updatedNode, latestNode) {
-		log.Info("Updating Node taints", "instance", updatedNode.Name, "taints added", taintChanges.taintsAdded, "taints removed", taintChanges.taintsRemoved, "taintLess", taintLess, "readySinceValue", readySinceValue)
+// DaemonsetNodeCounts counts, for each configured daemonset, the nodes it is required on and the ones still tainted for it
+func (h *Handler) DaemonsetNodeCounts(ctx context.Context) ([]DaemonsetNodeCount, error) {
+	counts, _, err := h.nodeCounts(ctx)
+	return counts, err
+}
 
-		//err := h.Patch(ctx, updatedNode, client.StrategicMergeFrom(latestNode))
-		err := h.Update(ctx, updatedNode)
+// nodeCounts returns the node counts of every configured daemonset, along with the number of nodes
+// matched by at least one daemonset and carrying none of the nidhogg taints
+func (h *Handler) nodeCounts(ctx context.Context) ([]DaemonsetNodeCount, int, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-		if err != nil {
-			taintOperationErrors.WithLabelValues("nodeUpdate").Inc()
-			return reconcile.Result{}, err
-		} else {
-			log.Info("Node taints updated.")
+	nodes := &corev1.NodeList{}
+	if err := h.List(ctx, nodes); err != nil {
+		return nil, 0, fmt.Errorf("error listing nodes: %v", err)
+	}
+
+	matched := make(map[string]bool)
+	counts := make([]DaemonsetNodeCount, 0, len(h.config.Daemonsets))
+	for _, daemonset := range h.config.Daemonsets {
+		count := DaemonsetNodeCount{Daemonset: daemonset}
+		taint := h.getTaintName(daemonset)
+		for i := range nodes.Items {
+			node := &nodes.Items[i]
+			if !h.requiresDaemonset(ctx, daemonset, node) {
+				continue
+			}
+			matched[node.Name] = true
+			count.Matching++
+			if hasTaint(node.Spec.Taints, taint) {
+				count.Tainted++
+			}
 		}
-		for _, taintAdded := range taintChanges.taintsAdded {
-			taintOperations.WithLabelValues(taintOperationAdded, taintAdded).Inc()
+		counts = append(counts, count)
+	}
+
+	ready := 0
+	for _, node := range nodes.Items {
+		if matched[node.Name] && !slices.ContainsFunc(node.Spec.Taints, func(taint corev1.Taint) bool {
+			return strings.HasPrefix(taint.Key, h.getTaintNamePrefix())
+		}) {
+			ready++
 		}
-		for _, taintRemoved := range taintChanges.taintsRemoved {
-			taintOperations.WithLabelValues(taintOperationRemoved, taintRemoved).Inc()
+	}
+	return counts, ready, nil
+}
+
+// requiresDaemonset reports whether the node needs the daemonset, matching the configured NodeSelector
+// or, when none is configured, the placement of the daemonset itself
+func (h *Handler) requiresDaemonset(ctx context.Context, daemonset Daemonset, node *corev1.Node) bool {
+	//If NodeSelector was not provided upfront through config
+	if daemonset.NodeSelector == nil && h.config.NodeSelector == nil {
+		if placement := h.getDaemonsetPlacement(ctx, daemonset); placement != nil {
+			return placement.Schedules(node, h.getTaintNamePrefix())
 		}
+	}
+	return h.config.DaemonsetSelectors[daemonset.key()].Matches(labels.Set(node.Labels))
+}
 
-		// this is a hack to make the event work on a non-namespaced object
-		updatedNode.UID = types.UID(updatedNode.Name)
+// getDaemonsetPlacement returns the placement of the daemonset as last seen by the informer, or read from the cache
+// when it hasn't been delivered yet. It is nil when the daemonset doesn't exist.
+func (h *Handler) getDaemonsetPlacement(ctx context.Context, daemonset Daemonset) *DaemonsetPlacement {
+	h.placementsMu.Lock()
+	placement, ok := h.watchedPlacements[daemonset.key()]
+	h.placementsMu.Unlock()
+	if ok {
+		return placement
+	}
 
-		h.recorder.Eventf(updatedNode, corev1.EventTypeNormal, "TaintsChanged", "Taints added: %s, Taints removed: %s, TaintLess: %v, FirstTimeReady: %q", taintChanges.taintsAdded, taintChanges.taintsRemoved, taintLess, readySinceValue)
+	placement, err := h.getPlacementFromDaemonSet(ctx, daemonset)
+	if err != nil {
+		logf.Log.Info(fmt.Sprintf("Could not fetch placement from daemonset %s in namespace %s", daemonset.Name, daemonset.Namespace))
+		return nil
 	}
+	h.storeDaemonsetPlacement(daemonset.key(), placement)
+	return placement
+}
+
+// SetDaemonsetPlacement records the placement of a daemonset delivered by the informer and returns the previous one, if any
+func (h *Handler) SetDaemonsetPlacement(ds *appsv1.DaemonSet) *DaemonsetPlacement {
+	return h.storeDaemonsetPlacement(types.NamespacedName{Namespace: ds.Namespace, Name: ds.Name}, NewDaemonsetPlacement(ds))
+}
 
-	return reconcile.Result{}, nil
+// ForgetDaemonsetPlacement records that a daemonset was deleted and returns its previous placement, if any.
+// Its nodes stop waiting for it until it is created again.
+func (h *Handler) ForgetDaemonsetPlacement(ds *appsv1.DaemonSet) *DaemonsetPlacement {
+	return h.storeDaemonsetPlacement(types.NamespacedName{Namespace: ds.Namespace, Name: ds.Name}, nil)
 }
 
-func (h *Handler) getSelectorFromDaemonSet(ctx context.Context, daemonset Daemonset) (labels.Selector, error) {
+// UsesDaemonsetPlacement reports whether the daemonset is configured and the nodes requiring it are picked by its own placement
+func (h *Handler) UsesDaemonsetPlacement(key types.NamespacedName) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, daemonset := range h.config.Daemonsets {
+		if daemonset.key() == key {
+			return daemonset.NodeSelector == nil && h.config.NodeSelector == nil
+		}
+	}
+	return false
+}
+
+func (h *Handler) storeDaemonsetPlacement(key types.NamespacedName, placement *DaemonsetPlacement) *DaemonsetPlacement {
+	h.placementsMu.Lock()
+	defer h.placementsMu.Unlock()
+
+	if h.watchedPlacements == nil {
+		h.watchedPlacements = make(map[types.NamespacedName]*DaemonsetPlacement)
+	}
+	previous := h.watchedPlacements[key]
+	h.watchedPlacements[key] = placement
+	return previous
+}
+
+func (h *Handler) getPlacementFromDaemonSet(ctx context.Context, daemonset Daemonset) (*DaemonsetPlacement, error) {
 	ds := &appsv1.DaemonSet{}
 	err := h.Get(ctx, types.NamespacedName{Namespace: daemonset.Namespace, Name: daemonset.Name}, ds)
 	if err != nil {
 		logf.Log.Info(fmt.Sprintf("Could not fetch daemonset %s from namespace %s", daemonset.Name, daemonset.Namespace))
 		return nil, err
 	}
-	selector := labels.SelectorFromSet(ds.Spec.Template.Spec.NodeSelector)
-
-	return selector, nil
+	return NewDaemonsetPlacement(ds), nil
 }
 
 func (h *Handler) calculateTaints(ctx context.Context, instance *corev1.Node) (*corev1.Node, taintChanges, error) {
 
 	nodeCopy := instance.DeepCopy()
+	now := time.Now().UTC().Format(time.RFC3339)
 
 	var changes taintChanges
 
@@ -208,73 +678,232 @@ func (h *Handler) calculateTaints(ctx context.Context, instance *corev1.Node) (*
 			taintsToRemove[taint.Key] = struct{}{}
 		}
 	}
-	for _, daemonset := range h.config.Daemonsets {
+	// daemonsets required on the node, and those among them whose taint is gone, to tell when a dependency is met
+	required := make(map[types.NamespacedName]bool)
+	cleared := make(map[types.NamespacedName]bool)
+	audited := h.auditedDaemonsets()
+	configured := make(map[string]bool)
+	for _, requirement := range h.config.orderedRequirements() {
+		// the daemonsets of a group share their taint settings
+		daemonset := requirement.daemonsets[0]
+		taint := h.getTaintName(daemonset)
+		taintEffect := h.getTaintEffect(daemonset)
+		configured[taint] = true
+
+		//make sure the daemonsets are required on the node, the taint of a group waits for enough of its daemonsets required there
+		var members []Daemonset
+		readyMembers := 0
+		for _, member := range requirement.daemonsets {
+			if !h.requiresDaemonset(ctx, member, instance) {
+				continue
+			}
+			required[member.key()] = true
+			members = append(members, member)
 
-		//If NodeSelector was not provided upfront through config
-		if h.config.NodeSelector == nil {
-			//Will try to get selectors from daemonset directly
-			selector, err := h.getSelectorFromDaemonSet(ctx, daemonset)
+			memberReady, err := h.daemonsetReady(ctx, nodeCopy, member, taint)
 			if err != nil {
-				logf.Log.Info(fmt.Sprintf("Could not fetch selector from daemonset %s in namespace %s", daemonset.Name, daemonset.Namespace))
-			} else {
-				//Override existing daemonset selector with the one freshly retrieved from the daemonset
-				h.config.DaemonsetSelectors[daemonset] = selector
+				return nil, taintChanges{}, err
+			}
+			if memberReady {
+				readyMembers++
 			}
 		}
 
-		//make sure daemonset selector matches node selector
-		if h.config.DaemonsetSelectors[daemonset].Matches(labels.Set(instance.Labels)) {
-			taint := fmt.Sprintf("%s/%s.%s", h.getTaintNamePrefix(), daemonset.Namespace, daemonset.Name)
-			taintEffect := h.getTaintEffect()
-			// Get Pod for nodeName
-			pods, err := h.getDaemonsetPods(ctx, instance.Name, daemonset)
-			if err != nil {
-				return nil, taintChanges{}, fmt.Errorf("error fetching pods: %v", err)
+		if len(members) > 0 {
+			ready := readyMembers >= requirement.readyNeeded(len(members))
+
+			// a daemonset isn't ready as long as the daemonsets it depends on still taint the node,
+			// an enforced one doesn't wait for audited daemonsets as their taint never is on the node
+			ignored := audited
+			if h.isAudited(daemonset) {
+				ignored = nil
+			}
+			unmet := unmetDependencies(members, required, cleared, ignored)
+			if setBlockedBy(nodeCopy, taint, unmet) {
+				changes.taintsBlocked = append(changes.taintsBlocked, taint)
 			}
 
-			if len(pods) == 0 || (len(pods) > 0 && !utils.AllTrue(pods, func(pod *corev1.Pod) bool { return podReady(pod) })) {
+			if !ready || len(unmet) > 0 {
 				// pod doesn't exist or is not ready
 				_, ok := taintsToRemove[taint]
 				if ok {
 					// we want to keep this already existing taint on it
 					delete(taintsToRemove, taint)
+					// and restart its removal delay the next time the pod is ready
+					delete(nodeCopy.Annotations, taint+readyObservedAtAnnotationSuffix)
+				} else if _, started := nodeCopy.Annotations[taint+daemonsetReadySinceAnnotationSuffix]; started && h.getStartupOnly(daemonset) {
+					// the node already started with this daemonset ready, its unreadiness is only reported
+					changes.taintsSuppressed = append(changes.taintsSuppressed, taint)
 				} else {
 					// taint is not already present, adding it
 					changes.taintsAdded = append(changes.taintsAdded, taint)
 					nodeCopy.Spec.Taints = addTaint(nodeCopy.Spec.Taints, taint, taintEffect)
+					delete(nodeCopy.Annotations, taint+daemonsetReadySinceAnnotationSuffix)
+					setAnnotation(nodeCopy, taint+daemonsetTaintedSinceAnnotationSuffix, now)
+				}
+			} else if _, ok := taintsToRemove[taint]; !ok {
+				// the pod was ready before the node was ever tainted for it
+				if _, ok := nodeCopy.Annotations[taint+daemonsetReadySinceAnnotationSuffix]; !ok {
+					setAnnotation(nodeCopy, taint+daemonsetReadySinceAnnotationSuffix, now)
 				}
+				markCleared(cleared, members)
+			} else if remaining := h.remainingTaintRemovalDelay(nodeCopy, taint); remaining > 0 {
+				// the dependent daemonsets wait for the delay as well
+				delete(taintsToRemove, taint)
+				changes.delay(taint, remaining)
+			} else {
+				// removed along with the taints left over by a previous configuration below
+				markCleared(cleared, members)
 			}
 		}
 	}
 
 	for taint := range taintsToRemove {
-		h.applyTaintRemovalDelay()
+		if remaining := h.remainingTaintRemovalDelay(nodeCopy, taint); remaining > 0 {
+			changes.delay(taint, remaining)
+			continue
+		}
+		delete(nodeCopy.Annotations, taint+readyObservedAtAnnotationSuffix)
+		delete(nodeCopy.Annotations, taint+daemonsetBlockedByAnnotationSuffix)
+		delete(nodeCopy.Annotations, taint+daemonsetTaintedSinceAnnotationSuffix)
+		if configured[taint] {
+			setAnnotation(nodeCopy, taint+daemonsetReadySinceAnnotationSuffix, now)
+		}
 		nodeCopy.Spec.Taints = removeTaint(nodeCopy.Spec.Taints, taint)
 		changes.taintsRemoved = append(changes.taintsRemoved, taint)
 	}
+	h.removeStaleTaintAnnotations(nodeCopy, configured)
 	return nodeCopy, changes, nil
 }
 
-func (h *Handler) applyTaintRemovalDelay() {
-	if h.config.TaintRemovalDelayInSeconds == 0 {
-		return
+// removeStaleTaintAnnotations deletes the annotations of the taints which aren't configured anymore, once they are gone from the node
+func (h *Handler) removeStaleTaintAnnotations(node *corev1.Node, configured map[string]bool) {
+	prefix := h.getTaintNamePrefix() + "/"
+	for key := range node.Annotations {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		for _, suffix := range taintAnnotationKeys("") {
+			taint, ok := strings.CutSuffix(key, suffix)
+			if ok && !configured[taint] && !hasTaint(node.Spec.Taints, taint) {
+				delete(node.Annotations, key)
+			}
+		}
 	}
-	logf.Log.Info("Daemonset is running, a delay has been set before removing taint.", "delay", h.config.TaintRemovalDelayInSeconds)
-	time.Sleep(time.Duration(h.config.TaintRemovalDelayInSeconds) * time.Second)
+}
+
+// daemonsetReady reports whether the pods of the daemonset on the node are ready, and run its current revision when required
+// until the node is first ready for the taint
+func (h *Handler) daemonsetReady(ctx context.Context, node *corev1.Node, daemonset Daemonset, taint string) (bool, error) {
+	// Get Pod for nodeName
+	pods, err := h.getDaemonsetPods(ctx, node.Name, daemonset)
+	if err != nil {
+		return false, fmt.Errorf("error fetching pods: %v", err)
+	}
+
+	ready := len(pods) > 0 && utils.AllTrue(pods, func(pod *corev1.Pod) bool { return podReady(pod, daemonset.Readiness) })
+	if ready && !h.readyBefore(node, taint) && h.getRequireCurrentRevision(daemonset) {
+		// until the node is first ready for the daemonset, its pod must not be left behind by a rollout
+		if ready, err = h.podsOnCurrentRevision(ctx, daemonset, pods); err != nil {
+			return false, fmt.Errorf("error fetching daemonset revision: %v", err)
+		}
+	}
+	return ready, nil
+}
+
+// readyBefore tells whether the node was already ready for the taint. Nodes which were fully ready before the annotations
+// of each taint were introduced only have the annotations of the node.
+func (h *Handler) readyBefore(node *corev1.Node, taint string) bool {
+	for _, key := range []string{
+		taint + daemonsetReadySinceAnnotationSuffix,
+		h.getTaintNamePrefix() + readySinceAnnotationSuffix,
+		h.getTaintNamePrefix() + firstReadyAnnotationSuffix,
+	} {
+		if _, ok := node.Annotations[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remainingTaintRemovalDelay returns how long the taint must stay on the node now that its daemonset pod is ready.
+// The time the pod was first seen ready is stored in an annotation, so the delay survives restarts and leader changes.
+func (h *Handler) remainingTaintRemovalDelay(node *corev1.Node, taint string) time.Duration {
+	delay := time.Duration(h.getTaintRemovalDelayInSeconds(taint)) * time.Second
+	if delay == 0 {
+		return 0
+	}
+
+	now := time.Now().UTC()
+	key := taint + readyObservedAtAnnotationSuffix
+	observedAt, err := time.Parse(time.RFC3339, node.Annotations[key])
+	if err != nil {
+		logf.Log.Info("Daemonset is running, a delay has been set before removing taint.", "taint", taint, "delay", delay)
+		setAnnotation(node, key, now.Format(time.RFC3339))
+		return delay
+	}
+	return observedAt.Add(delay).Sub(now)
+}
+
+// getTaintRemovalDelayInSeconds returns the delay of the daemonset owning the taint, falling back to the global one
+func (h *Handler) getTaintRemovalDelayInSeconds(taint string) int {
+	for _, daemonset := range h.config.Daemonsets {
+		if daemonset.TaintRemovalDelayInSeconds != nil && h.getTaintName(daemonset) == taint {
+			return *daemonset.TaintRemovalDelayInSeconds
+		}
+	}
+	return h.config.TaintRemovalDelayInSeconds
+}
+
+// getStartupOnly returns the startup only mode of the daemonset, falling back to the global one
+func (h *Handler) getStartupOnly(daemonset Daemonset) bool {
+	if daemonset.StartupOnly != nil {
+		return *daemonset.StartupOnly
+	}
+	return h.config.StartupOnly
+}
+
+// getRequireCurrentRevision returns whether the daemonset pod must run the current revision, falling back to the global setting
+func (h *Handler) getRequireCurrentRevision(daemonset Daemonset) bool {
+	if daemonset.RequireCurrentRevision != nil {
+		return *daemonset.RequireCurrentRevision
+	}
+	return h.config.RequireCurrentRevision
 }
 
 func (h *Handler) getTaintNamePrefix() string {
-	if h.config.TaintNamePrefix != "" {
-		return h.config.TaintNamePrefix
+	return h.config.taintNamePrefix()
+}
+
+func (h *Handler) getTaintName(daemonset Daemonset) string {
+	return h.config.taintName(daemonset)
+}
+
+func (hc HandlerConfig) taintNamePrefix() string {
+	if hc.TaintNamePrefix != "" {
+		return hc.TaintNamePrefix
 	}
 
 	return defaultTaintKeyPrefix
 }
 
-func (h *Handler) getTaintEffect() corev1.TaintEffect {
+// taintName returns the taint of the daemonset, shared with the other daemonsets of its group if any
+func (hc HandlerConfig) taintName(daemonset Daemonset) string {
+	if daemonset.Group != "" {
+		return fmt.Sprintf("%s/%s", hc.taintNamePrefix(), daemonset.Group)
+	}
+	return fmt.Sprintf("%s/%s.%s", hc.taintNamePrefix(), daemonset.Namespace, daemonset.Name)
+}
+
+func (h *Handler) getTaintEffect(daemonset Daemonset) corev1.TaintEffect {
 	var effect corev1.TaintEffect
 
-	switch h.config.TaintEffect {
+	configuredEffect := h.config.TaintEffect
+	if daemonset.TaintEffect != "" {
+		configuredEffect = daemonset.TaintEffect
+	}
+
+	switch configuredEffect {
 	case "NoSchedule":
 		effect = corev1.TaintEffectNoSchedule
 	case "PreferNoSchedule":
@@ -288,37 +917,53 @@ func (h *Handler) getTaintEffect() corev1.TaintEffect {
 	return effect
 }
 
+// getDaemonsetPods returns the pods on the node controlled by the current incarnation of the daemonset,
+// pods left over by a deleted daemonset of the same name are ignored
 func (h *Handler) getDaemonsetPods(ctx context.Context, nodeName string, ds Daemonset) ([]*corev1.Pod, error) {
-	opts := client.InNamespace(ds.Namespace)
+	daemonset := &appsv1.DaemonSet{}
+	err := h.Get(ctx, types.NamespacedName{Namespace: ds.Namespace, Name: ds.Name}, daemonset)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
 	pods := &corev1.PodList{}
-	err := h.List(ctx, pods, opts)
+	err = h.List(ctx, pods, client.InNamespace(ds.Namespace), client.MatchingFields{
+		podNodeNameField:     nodeName,
+		podDaemonsetUIDField: string(daemonset.UID),
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	matchingPods := make([]*corev1.Pod, 0)
-	for i, pod := range pods.Items {
-		for _, owner := range pod.OwnerReferences {
-			if owner.Name == ds.Name && pod.Spec.NodeName == nodeName {
-				matchingPods = append(matchingPods, &pods.Items[i])
-			}
-		}
+	matchingPods := make([]*corev1.Pod, 0, len(pods.Items))
+	for i := range pods.Items {
+		matchingPods = append(matchingPods, &pods.Items[i])
 	}
 
 	return matchingPods, nil
 }
 
-func podReady(pod *corev1.Pod) bool {
-	for _, condition := range pod.Status.Conditions {
-		if condition.Type == corev1.PodReady {
-			return condition.Status == corev1.ConditionTrue
-		}
+func addTaint(taints []corev1.Taint, taintName string, taintEffect corev1.TaintEffect) []corev1.Taint {
+	return append(taints, corev1.Taint{Key: taintName, Effect: taintEffect})
+}
+
+func setAnnotation(node *corev1.Node, key, value string) {
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
 	}
-	return false
+	node.Annotations[key] = value
 }
 
-func addTaint(taints []corev1.Taint, taintName string, taintEffect corev1.TaintEffect) []corev1.Taint {
-	return append(taints, corev1.Taint{Key: taintName, Effect: taintEffect})
+func hasTaint(taints []corev1.Taint, taintName string) bool {
+	for _, taint := range taints {
+		if taint.Key == taintName {
+			return true
+		}
+	}
+	return false
 }
 
 func removeTaint(taints []corev1.Taint, taintName string) []corev1.Taint {
@@ -2,14 +2,25 @@ package nidhogg
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
@@ -26,6 +37,8 @@ const (
 	taintName       = taintNamePrefix + "/" + namespace + "." + daemonset
 )
 
+var isController = true
+
 func TestCalculateTaintsWithReadyPod(t *testing.T) {
 	ctx := context.TODO()
 	node := buildNode(namespace, []string{daemonset})
@@ -33,7 +46,7 @@ func TestCalculateTaintsWithReadyPod(t *testing.T) {
 	cfg := buildNidhoggConfig(namespace, []string{daemonset})
 	cfg.BuildSelectors()
 
-	handler := buildHandler([]corev1.Pod{pod}, nil, cfg)
+	handler := buildHandler([]corev1.Pod{pod}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
 	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
 
 	assert.NoError(t, err)
@@ -68,7 +81,7 @@ func TestCalculateTaintsWithMultipleDaemonsets(t *testing.T) {
 	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
 	cfg.BuildSelectors()
 
-	handler := buildHandler([]corev1.Pod{pod1, pod2}, nil, cfg)
+	handler := buildHandler([]corev1.Pod{pod1, pod2}, []appsv1.DaemonSet{buildDaemonset(daemonset1), buildDaemonset(daemonset2)}, cfg)
 	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
 
 	assert.NoError(t, err)
@@ -79,6 +92,30 @@ func TestCalculateTaintsWithMultipleDaemonsets(t *testing.T) {
 	assert.Empty(t, changes.taintsAdded)
 }
 
+func TestCalculateTaintsForgetsTaintsOfPreviousConfig(t *testing.T) {
+	ctx := context.TODO()
+	// the second daemonset isn't configured anymore, its taint is still on the node and a third one was removed before
+	node := buildNode(namespace, []string{daemonset1, daemonset2})
+	leftover, removed := buildTaintName(namespace, daemonset2), buildTaintName(namespace, daemonset)
+	node.Annotations = map[string]string{
+		leftover + daemonsetTaintedSinceAnnotationSuffix: time.Now().UTC().Format(time.RFC3339),
+		removed + daemonsetReadySinceAnnotationSuffix:    time.Now().UTC().Format(time.RFC3339),
+		removed + daemonsetBlockedByAnnotationSuffix:     namespace + "/" + daemonset1,
+	}
+	pod1 := buildPod("pod1", daemonset1, corev1.PodReady)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1})
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod1}, []appsv1.DaemonSet{buildDaemonset(daemonset1)}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Empty(t, updatedNode.Spec.Taints)
+	assert.ElementsMatch(t, []string{buildTaintName(namespace, daemonset1), leftover}, changes.taintsRemoved)
+	assert.Len(t, updatedNode.Annotations, 1)
+	assert.Contains(t, updatedNode.Annotations, buildTaintName(namespace, daemonset1)+daemonsetReadySinceAnnotationSuffix)
+}
+
 func TestCalculateTaintsWithUnreadyPod(t *testing.T) {
 	ctx := context.TODO()
 	node := buildNode(namespace, []string{daemonset})
@@ -86,7 +123,7 @@ func TestCalculateTaintsWithUnreadyPod(t *testing.T) {
 	cfg := buildNidhoggConfig(namespace, []string{daemonset})
 	cfg.BuildSelectors()
 
-	handler := buildHandler([]corev1.Pod{pod}, nil, cfg)
+	handler := buildHandler([]corev1.Pod{pod}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
 	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
 
 	assert.NoError(t, err)
@@ -113,13 +150,29 @@ func TestCalculateTaintsWithUnreadyPodAndWithoutNodeSelector(t *testing.T) {
 	assert.Empty(t, changes.taintsAdded, taintName)
 }
 
+func TestCalculateTaintsOnNotReadyNodeWithoutNodeSelector(t *testing.T) {
+	ctx := context.TODO()
+	// registered by the kubelet, before the daemonset pod is created
+	node := buildNodeWithoutTaints(namespace, []string{daemonset})
+	node.Spec.Taints = []corev1.Taint{{Key: corev1.TaintNodeNotReady, Effect: corev1.TaintEffectNoSchedule}}
+	cfg := buildNidhoggConfigWithoutNodeSelector(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+
+	handler := buildHandler(nil, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Contains(t, updatedNode.Spec.Taints, corev1.Taint{Key: taintName, Effect: corev1.TaintEffectNoSchedule})
+	assert.Equal(t, []string{taintName}, changes.taintsAdded)
+}
+
 func TestGetDaemonsetPodsReturnsUniquePods(t *testing.T) {
 	ctx := context.TODO()
 	pod1 := buildPod("pod1", daemonset, corev1.PodReady)
 	pod2 := buildPod("pod2", daemonset, corev1.PodReady)
 	cfg := buildNidhoggConfig(namespace, []string{daemonset})
 
-	handler := buildHandler([]corev1.Pod{pod1, pod2}, nil, cfg)
+	handler := buildHandler([]corev1.Pod{pod1, pod2}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
 	daemonset := Daemonset{Name: daemonset, Namespace: namespace}
 	pods, err := handler.getDaemonsetPods(ctx, nodeName, daemonset)
 
@@ -130,6 +183,98 @@ func TestGetDaemonsetPodsReturnsUniquePods(t *testing.T) {
 	assert.Equal(t, pods[1].Name, pod2.Name)
 }
 
+func TestGetDaemonsetPodsOnlyReturnsPodsOfNodeAndDaemonset(t *testing.T) {
+	ctx := context.TODO()
+	pod1 := buildPod("pod1", daemonset, corev1.PodReady)
+	pod2 := buildPod("pod2", daemonset, corev1.PodReady)
+	pod2.Spec.NodeName = "otherNode"
+	pod3 := buildPod("pod3", daemonset2, corev1.PodReady)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+
+	handler := buildHandler([]corev1.Pod{pod1, pod2, pod3}, []appsv1.DaemonSet{buildDaemonset(daemonset), buildDaemonset(daemonset2)}, cfg)
+	pods, err := handler.getDaemonsetPods(ctx, nodeName, Daemonset{Name: daemonset, Namespace: namespace})
+
+	assert.NoError(t, err)
+	assert.Len(t, pods, 1)
+	assert.Equal(t, pod1.Name, pods[0].Name)
+}
+
+func TestGetDaemonsetPodsIgnoresPodsOfOtherControllers(t *testing.T) {
+	ctx := context.TODO()
+	pod1 := buildPod("pod1", daemonset, corev1.PodReady)
+	// left over by a deleted daemonset with the same name
+	pod2 := buildPod("pod2", daemonset, corev1.PodReady)
+	pod2.OwnerReferences[0].UID = "previous-uid"
+	// owned by a replicaset with the same name
+	pod3 := buildPod("pod3", daemonset, corev1.PodReady)
+	pod3.OwnerReferences[0].Kind = "ReplicaSet"
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+
+	handler := buildHandler([]corev1.Pod{pod1, pod2, pod3}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
+	pods, err := handler.getDaemonsetPods(ctx, nodeName, Daemonset{Name: daemonset, Namespace: namespace})
+
+	assert.NoError(t, err)
+	assert.Len(t, pods, 1)
+	assert.Equal(t, pod1.Name, pods[0].Name)
+}
+
+func TestCalculateTaintsWithDeletedDaemonset(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset})
+	pod := buildPod("pod", daemonset, corev1.PodReady)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod}, nil, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Contains(t, updatedNode.Spec.Taints, buildActiveTaint(namespace, daemonset))
+	assert.Empty(t, changes.taintsRemoved)
+}
+
+func TestRequiresDaemonsetUsesWatchedPlacement(t *testing.T) {
+	ctx := context.TODO()
+	cfg := buildNidhoggConfigWithoutNodeSelector(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+	ds := buildDaemonset(daemonset)
+	node := buildNode(namespace, []string{daemonset})
+	otherNode := buildNode(namespace, []string{daemonset})
+	otherNode.Labels = nil
+
+	handler := buildHandler(nil, nil, cfg)
+	previous := handler.SetDaemonsetPlacement(&ds)
+
+	assert.Nil(t, previous)
+	assert.True(t, handler.UsesDaemonsetPlacement(types.NamespacedName{Namespace: namespace, Name: daemonset}))
+	assert.True(t, handler.requiresDaemonset(ctx, cfg.Daemonsets[0], &node))
+	assert.False(t, handler.requiresDaemonset(ctx, cfg.Daemonsets[0], &otherNode))
+
+	// the nodes don't wait for the daemonset once it is deleted
+	assert.NotNil(t, handler.ForgetDaemonsetPlacement(&ds))
+	assert.False(t, handler.requiresDaemonset(ctx, cfg.Daemonsets[0], &node))
+}
+
+func TestCalculateTaintsIgnoresNodesOutsideDaemonsetAffinity(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, []string{daemonset})
+	ds := buildDaemonset(daemonset)
+	ds.Spec.Template.Spec.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+			MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "gpu", Operator: corev1.NodeSelectorOpExists}},
+		}}},
+	}}
+	cfg := buildNidhoggConfigWithoutNodeSelector(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+
+	handler := buildHandler(nil, []appsv1.DaemonSet{ds}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Empty(t, updatedNode.Spec.Taints)
+	assert.Empty(t, changes.taintsAdded)
+}
+
 func TestCalculateTaintsWithTaintEffect(t *testing.T) {
 	ctx := context.TODO()
 	node := buildNodeWithoutTaints(namespace, []string{daemonset})
@@ -137,7 +282,7 @@ func TestCalculateTaintsWithTaintEffect(t *testing.T) {
 	cfg := buildNidhoggConfigWithNoExecuteTaintEffect(namespace, []string{daemonset})
 	cfg.BuildSelectors()
 
-	handler := buildHandler([]corev1.Pod{pod}, nil, cfg)
+	handler := buildHandler([]corev1.Pod{pod}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
 	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
 
 	assert.NoError(t, err)
@@ -147,9 +292,428 @@ func TestCalculateTaintsWithTaintEffect(t *testing.T) {
 	assert.NotNil(t, changes.taintsAdded, taintName)
 }
 
+func TestCalculateTaintsWithDaemonsetTaintEffect(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, []string{daemonset1, daemonset2})
+	pod1 := buildPod("pod1", daemonset1, corev1.PodScheduled)
+	pod2 := buildPod("pod2", daemonset2, corev1.PodScheduled)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.Daemonsets[0].TaintEffect = "NoExecute"
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod1, pod2}, []appsv1.DaemonSet{buildDaemonset(daemonset1), buildDaemonset(daemonset2)}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Contains(t, updatedNode.Spec.Taints, buildActiveTaintWithNoExecuteTaintEffect(namespace, daemonset1))
+	assert.Contains(t, updatedNode.Spec.Taints, corev1.Taint{Key: buildTaintName(namespace, daemonset2), Effect: corev1.TaintEffectNoSchedule})
+	assert.Len(t, changes.taintsAdded, 2)
+}
+
+func TestCalculateTaintsWithDaemonsetNodeSelector(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, []string{daemonset1, daemonset2})
+	pod1 := buildPod("pod1", daemonset1, corev1.PodScheduled)
+	pod2 := buildPod("pod2", daemonset2, corev1.PodScheduled)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.Daemonsets[1].NodeSelector = []string{"pool=gpu"}
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod1, pod2}, []appsv1.DaemonSet{buildDaemonset(daemonset1), buildDaemonset(daemonset2)}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{buildTaintName(namespace, daemonset1)}, changes.taintsAdded)
+	assert.Len(t, updatedNode.Spec.Taints, 1)
+}
+
+func TestGetTaintRemovalDelayInSecondsWithDaemonsetOverride(t *testing.T) {
+	noDelay := 0
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.TaintRemovalDelayInSeconds = 10
+	cfg.Daemonsets[0].TaintRemovalDelayInSeconds = &noDelay
+
+	handler := buildHandler(nil, nil, cfg)
+
+	assert.Equal(t, 0, handler.getTaintRemovalDelayInSeconds(buildTaintName(namespace, daemonset1)))
+	assert.Equal(t, 10, handler.getTaintRemovalDelayInSeconds(buildTaintName(namespace, daemonset2)))
+}
+
+func TestCalculateTaintsDelaysTaintRemoval(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset})
+	pod := buildPod("pod", daemonset, corev1.PodReady)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.TaintRemovalDelayInSeconds = 10
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Contains(t, updatedNode.Spec.Taints, buildActiveTaint(namespace, daemonset))
+	assert.Contains(t, updatedNode.Annotations, taintName+readyObservedAtAnnotationSuffix)
+	assert.Empty(t, changes.taintsRemoved)
+	assert.Equal(t, []string{taintName}, changes.taintsDelayed)
+	assert.Equal(t, 10*time.Second, changes.requeueAfter)
+}
+
+func TestCalculateTaintsRemovesTaintAfterDelay(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset})
+	node.Annotations = map[string]string{
+		taintName + readyObservedAtAnnotationSuffix: time.Now().Add(-11 * time.Second).UTC().Format(time.RFC3339),
+	}
+	pod := buildPod("pod", daemonset, corev1.PodReady)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.TaintRemovalDelayInSeconds = 10
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, updatedNode.Spec.Taints, buildActiveTaint(namespace, daemonset))
+	assert.NotContains(t, updatedNode.Annotations, taintName+readyObservedAtAnnotationSuffix)
+	assert.Equal(t, []string{taintName}, changes.taintsRemoved)
+	assert.Zero(t, changes.requeueAfter)
+}
+
+func TestCalculateTaintsResetsDelayWhenPodIsUnready(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset})
+	node.Annotations = map[string]string{
+		taintName + readyObservedAtAnnotationSuffix: time.Now().Add(-5 * time.Second).UTC().Format(time.RFC3339),
+	}
+	pod := buildPod("pod", daemonset, corev1.PodScheduled)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.TaintRemovalDelayInSeconds = 10
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Contains(t, updatedNode.Spec.Taints, buildActiveTaint(namespace, daemonset))
+	assert.NotContains(t, updatedNode.Annotations, taintName+readyObservedAtAnnotationSuffix)
+	assert.Zero(t, changes.requeueAfter)
+}
+
+func TestBuildSelectorsCombinesNodeSelectors(t *testing.T) {
+	cfg := HandlerConfig{
+		Daemonsets:   buildDaemonsets(namespace, []string{daemonset}),
+		NodeSelector: []string{"role=node", "pool in (a, b)"},
+	}
+
+	assert.NoError(t, cfg.BuildSelectors())
+	selector := cfg.DaemonsetSelectors[types.NamespacedName{Namespace: namespace, Name: daemonset}]
+	assert.True(t, selector.Matches(labels.Set{"role": "node", "pool": "a"}))
+	assert.False(t, selector.Matches(labels.Set{"role": "node", "pool": "c"}))
+	assert.False(t, selector.Matches(labels.Set{"pool": "a"}))
+}
+
+func TestHandleNodePatchesTaints(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, []string{daemonset})
+	pod := buildPod("pod", daemonset, corev1.PodScheduled)
+	ds := buildDaemonset(daemonset)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+
+	c := newFakeClientBuilder().WithObjects(&node, &pod, &ds).Build()
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+	_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+	assert.NoError(t, err)
+
+	updatedNode := &corev1.Node{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: nodeName}, updatedNode))
+	assert.Contains(t, updatedNode.Spec.Taints, corev1.Taint{Key: taintName, Effect: corev1.TaintEffectNoSchedule})
+	assert.Equal(t, node.Labels, updatedNode.Labels)
+}
+
+func TestHandleNodeObservesReadyDurations(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset})
+	node.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+	pod := buildPod("pod", daemonset, corev1.PodReady)
+	ds := buildDaemonset(daemonset)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+	daemonsetBefore := histogramSampleCount(t, daemonsetTaintRemovalDuration.WithLabelValues(namespace, daemonset))
+	nodesBefore := histogramSampleCount(t, nodeReadyDuration)
+
+	c := newFakeClientBuilder().WithObjects(&node, &pod, &ds).Build()
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+	for i := 0; i < 2; i++ {
+		_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, daemonsetBefore+1, histogramSampleCount(t, daemonsetTaintRemovalDuration.WithLabelValues(namespace, daemonset)))
+	assert.Equal(t, nodesBefore+1, histogramSampleCount(t, nodeReadyDuration))
+}
+
+func TestHandleNodeObservesReadyDurationsOnceWithLastReady(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset})
+	node.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+	pod := buildPod("pod", daemonset, corev1.PodReady)
+	ds := buildDaemonset(daemonset)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.ReadySincePolicy = ReadySinceLastReady
+	cfg.BuildSelectors()
+	daemonsetBefore := histogramSampleCount(t, daemonsetTaintRemovalDuration.WithLabelValues(namespace, daemonset))
+	nodesBefore := histogramSampleCount(t, nodeReadyDuration)
+
+	c := newFakeClientBuilder().WithObjects(&node, &pod, &ds).Build()
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}}
+	// ready, tainted again once the pod isn't ready anymore, then ready again
+	for _, condition := range []corev1.PodConditionType{corev1.PodReady, corev1.PodScheduled, corev1.PodReady} {
+		pod.Status.Conditions[0].Type = condition
+		assert.NoError(t, c.Status().Update(ctx, &pod))
+		_, err := handler.HandleNode(ctx, request)
+		assert.NoError(t, err)
+	}
+
+	updatedNode := &corev1.Node{}
+	assert.NoError(t, c.Get(ctx, request.NamespacedName, updatedNode))
+	assert.Empty(t, updatedNode.Spec.Taints)
+	assert.Contains(t, updatedNode.Annotations, taintNamePrefix+readySinceAnnotationSuffix)
+	assert.Contains(t, updatedNode.Annotations, taintNamePrefix+firstReadyAnnotationSuffix)
+	assert.Equal(t, daemonsetBefore+1, histogramSampleCount(t, daemonsetTaintRemovalDuration.WithLabelValues(namespace, daemonset)))
+	assert.Equal(t, nodesBefore+1, histogramSampleCount(t, nodeReadyDuration))
+}
+
+func histogramSampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	metric := &dto.Metric{}
+	assert.NoError(t, observer.(prometheus.Metric).Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestHandleNodeRecordsReadySince(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset})
+	pod := buildPod("pod", daemonset, corev1.PodReady)
+	ds := buildDaemonset(daemonset)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+
+	c := newFakeClientBuilder().WithObjects(&node, &pod, &ds).Build()
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+	_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+	assert.NoError(t, err)
+
+	updatedNode := &corev1.Node{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: nodeName}, updatedNode))
+	readySince, err := time.Parse(time.RFC3339, updatedNode.Annotations[taintNamePrefix+readySinceAnnotationSuffix])
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), readySince, time.Minute)
+	assert.Equal(t, time.UTC, readySince.Location())
+	assert.Contains(t, updatedNode.Annotations, taintName+daemonsetReadySinceAnnotationSuffix)
+	assert.NotContains(t, updatedNode.Annotations, taintName+daemonsetTaintedSinceAnnotationSuffix)
+}
+
+func TestHandleNodeReadySincePolicy(t *testing.T) {
+	for policy, cleared := range map[string]bool{"": false, ReadySinceFirstReady: false, ReadySinceLastReady: true} {
+		ctx := context.TODO()
+		node := buildNodeWithoutTaints(namespace, []string{daemonset})
+		node.Annotations = map[string]string{
+			taintNamePrefix + readySinceAnnotationSuffix:    "2006-01-02T15:04:05Z",
+			taintName + daemonsetReadySinceAnnotationSuffix: "2006-01-02T15:04:05Z",
+		}
+		pod := buildPod("pod", daemonset, corev1.PodScheduled)
+		ds := buildDaemonset(daemonset)
+		cfg := buildNidhoggConfig(namespace, []string{daemonset})
+		cfg.ReadySincePolicy = policy
+		cfg.BuildSelectors()
+
+		c := newFakeClientBuilder().WithObjects(&node, &pod, &ds).Build()
+		handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+		_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+		assert.NoError(t, err)
+
+		updatedNode := &corev1.Node{}
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: nodeName}, updatedNode))
+		_, kept := updatedNode.Annotations[taintNamePrefix+readySinceAnnotationSuffix]
+		assert.Equal(t, cleared, !kept, "policy %q", policy)
+		assert.NotContains(t, updatedNode.Annotations, taintName+daemonsetReadySinceAnnotationSuffix)
+		assert.Contains(t, updatedNode.Annotations, taintName+daemonsetTaintedSinceAnnotationSuffix)
+	}
+}
+
+func TestCalculateTaintsStartupOnly(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, []string{daemonset1, daemonset2})
+	node.Annotations = map[string]string{
+		buildTaintName(namespace, daemonset1) + daemonsetReadySinceAnnotationSuffix: "2006-01-02T15:04:05Z",
+		buildTaintName(namespace, daemonset2) + daemonsetReadySinceAnnotationSuffix: "2006-01-02T15:04:05Z",
+	}
+	pod1 := buildPod("pod1", daemonset1, corev1.PodScheduled)
+	pod2 := buildPod("pod2", daemonset2, corev1.PodScheduled)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.StartupOnly = true
+	startupOnly := false
+	cfg.Daemonsets[1].StartupOnly = &startupOnly
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod1, pod2}, []appsv1.DaemonSet{buildDaemonset(daemonset1), buildDaemonset(daemonset2)}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{buildTaintName(namespace, daemonset1)}, changes.taintsSuppressed)
+	assert.Equal(t, []string{buildTaintName(namespace, daemonset2)}, changes.taintsAdded)
+	assert.NotContains(t, updatedNode.Spec.Taints, buildActiveTaint(namespace, daemonset1))
+}
+
+func TestCalculateTaintsStartupOnlyTaintsNodesWhichNeverStarted(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, []string{daemonset})
+	pod := buildPod("pod", daemonset, corev1.PodScheduled)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.StartupOnly = true
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
+	_, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.Empty(t, changes.taintsSuppressed)
+	assert.Equal(t, []string{taintName}, changes.taintsAdded)
+}
+
+func TestHandleNodeRetriesOnConflict(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, []string{daemonset})
+	pod := buildPod("pod", daemonset, corev1.PodScheduled)
+	ds := buildDaemonset(daemonset)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+
+	patches := 0
+	c := newFakeClientBuilder().WithObjects(&node, &pod, &ds).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			patches++
+			if patches == 1 {
+				return apierrors.NewConflict(corev1.Resource("nodes"), obj.GetName(), nil)
+			}
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+	}).Build()
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+	apiReader := &countingReader{Reader: c}
+	handler.SetAPIReader(apiReader)
+	_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, patches)
+	// only the retry bypasses the cache
+	assert.Equal(t, 1, apiReader.gets)
+
+	updatedNode := &corev1.Node{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: nodeName}, updatedNode))
+	assert.Contains(t, updatedNode.Spec.Taints, corev1.Taint{Key: taintName, Effect: corev1.TaintEffectNoSchedule})
+}
+
+func TestHandleNodeReleasesLockWhilePatching(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNodeWithoutTaints(namespace, []string{daemonset})
+	pod := buildPod("pod", daemonset, corev1.PodScheduled)
+	ds := buildDaemonset(daemonset)
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+
+	var handler *Handler
+	configChanged := false
+	c := newFakeClientBuilder().WithObjects(&node, &pod, &ds).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			// a configuration change doesn't wait for the API server
+			done := make(chan struct{})
+			go func() {
+				handler.SetConfig(cfg)
+				close(done)
+			}()
+			select {
+			case <-done:
+				configChanged = true
+			case <-time.After(5 * time.Second):
+			}
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+	}).Build()
+	handler = NewHandler(c, record.NewFakeRecorder(10), cfg)
+	_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+	assert.NoError(t, err)
+	assert.True(t, configChanged)
+}
+
+type countingReader struct {
+	client.Reader
+	gets int
+}
+
+func (r *countingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	r.gets++
+	return r.Reader.Get(ctx, key, obj, opts...)
+}
+
+func TestHandleNodeConcurrently(t *testing.T) {
+	ctx := context.TODO()
+	ds := buildDaemonset(daemonset)
+	cfg := buildNidhoggConfigWithoutNodeSelector(namespace, []string{daemonset})
+	cfg.BuildSelectors()
+
+	builder := newFakeClientBuilder().WithObjects(&ds)
+	for i := 0; i < 10; i++ {
+		node := buildNodeWithoutTaints(namespace, []string{daemonset})
+		node.Name = fmt.Sprintf("node%d", i)
+		builder = builder.WithObjects(&node)
+	}
+	c := builder.Build()
+	handler := NewHandler(c, record.NewFakeRecorder(100), cfg)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := handler.HandleNode(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: fmt.Sprintf("node%d", i)}})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		handler.SetConfig(cfg)
+	}()
+	wg.Wait()
+
+	for i := 0; i < 10; i++ {
+		node := &corev1.Node{}
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Name: fmt.Sprintf("node%d", i)}, node))
+		assert.Contains(t, node.Spec.Taints, corev1.Taint{Key: taintName, Effect: corev1.TaintEffectNoSchedule})
+	}
+}
+
+func TestDaemonsetNodeCounts(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset1})
+	cfg := buildNidhoggConfig(namespace, []string{daemonset1, daemonset2})
+	cfg.BuildSelectors()
+
+	handler := buildHandler(nil, nil, cfg)
+	assert.NoError(t, handler.Create(ctx, &node))
+	counts, err := handler.DaemonsetNodeCounts(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []DaemonsetNodeCount{
+		{Daemonset: Daemonset{Name: daemonset1, Namespace: namespace}, Matching: 1, Tainted: 1},
+		{Daemonset: Daemonset{Name: daemonset2, Namespace: namespace}, Matching: 1, Tainted: 0},
+	}, counts)
+}
+
 func buildHandler(pods []corev1.Pod, daemonsets []appsv1.DaemonSet, config HandlerConfig) Handler {
 	return Handler{
-		Client: fake.NewClientBuilder().WithLists(&corev1.PodList{
+		Client: newFakeClientBuilder().WithLists(&corev1.PodList{
 			TypeMeta: metav1.TypeMeta{},
 			ListMeta: metav1.ListMeta{},
 			Items:    pods,
@@ -159,10 +723,17 @@ func buildHandler(pods []corev1.Pod, daemonsets []appsv1.DaemonSet, config Handl
 			Items:    daemonsets,
 		}).Build(),
 		recorder: record.NewFakeRecorder(0),
+		static:   config,
 		config:   config,
 	}
 }
 
+func newFakeClientBuilder() *fake.ClientBuilder {
+	return fake.NewClientBuilder().
+		WithIndex(&corev1.Pod{}, podNodeNameField, indexPodNodeName).
+		WithIndex(&corev1.Pod{}, podDaemonsetUIDField, indexPodDaemonsetUID)
+}
+
 func buildDaemonsets(namespace string, daemonsetNames []string) []Daemonset {
 	var daemonsets []Daemonset
 	for _, daemonsetName := range daemonsetNames {
@@ -201,9 +772,15 @@ func buildNidhoggConfigWithoutNodeSelector(namespace string, daemonsets []string
 func buildPod(podName string, daemonsetName string, conditionType corev1.PodConditionType) corev1.Pod {
 	return corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:            podName,
-			Namespace:       namespace,
-			OwnerReferences: []metav1.OwnerReference{{Name: daemonsetName}},
+			Name:      podName,
+			Namespace: namespace,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "DaemonSet",
+				Name:       daemonsetName,
+				UID:        buildDaemonsetUID(daemonsetName),
+				Controller: &isController,
+			}},
 		},
 		Spec: corev1.PodSpec{
 			NodeName: nodeName,
@@ -225,6 +802,7 @@ func buildDaemonset(daemonsetName string) appsv1.DaemonSet {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      daemonsetName,
 			Namespace: namespace,
+			UID:       buildDaemonsetUID(daemonsetName),
 		},
 		Spec: appsv1.DaemonSetSpec{
 			Template: corev1.PodTemplateSpec{
@@ -238,6 +816,10 @@ func buildDaemonset(daemonsetName string) appsv1.DaemonSet {
 	}
 }
 
+func buildDaemonsetUID(daemonsetName string) types.UID {
+	return types.UID(daemonsetName + "-uid")
+}
+
 func buildNode(namespace string, daemonsets []string) corev1.Node {
 	return corev1.Node{
 		ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,40 @@
+package nidhogg
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	podNodeNameField     = "spec.nodeName"
+	podDaemonsetUIDField = "metadata.ownerReferences.daemonsetUID"
+	daemonsetOwnerKind   = "DaemonSet"
+)
+
+// IndexPodFields registers the pod fields the handler lists pods by, so a reconcile only reads the pods of its node
+func IndexPodFields(ctx context.Context, indexer client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &corev1.Pod{}, podNodeNameField, indexPodNodeName); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &corev1.Pod{}, podDaemonsetUIDField, indexPodDaemonsetUID)
+}
+
+func indexPodNodeName(obj client.Object) []string {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil
+	}
+	return []string{pod.Spec.NodeName}
+}
+
+// indexPodDaemonsetUID indexes the pods by the UID of the DaemonSet controlling them
+func indexPodDaemonsetUID(obj client.Object) []string {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.Kind != daemonsetOwnerKind {
+		return nil
+	}
+	return []string{string(owner.UID)}
+}
@@ -0,0 +1,80 @@
+package nidhogg
+
+import (
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingcorev1 "k8s.io/component-helpers/scheduling/corev1"
+	"k8s.io/component-helpers/scheduling/corev1/nodeaffinity"
+	"k8s.io/klog/v2"
+)
+
+// taintExternalCloudProvider is set by the kubelet when an external cloud provider has yet to initialize the node
+const taintExternalCloudProvider = "node.cloudprovider.kubernetes.io/uninitialized"
+
+// bootstrapTaints are set on nodes which are still starting or temporarily lost, whatever their effect. They go away on their own
+// and must not make nidhogg lift its taints while the node is bootstrapping, so nidhogg leaves them out of the placement check.
+var bootstrapTaints = []string{corev1.TaintNodeNotReady, corev1.TaintNodeUnreachable, taintExternalCloudProvider}
+
+// daemonsetTolerations are added by the DaemonSet controller to every daemonset pod
+var daemonsetTolerations = []corev1.Toleration{
+	{Key: corev1.TaintNodeNotReady, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeUnreachable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	{Key: corev1.TaintNodeDiskPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeMemoryPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodePIDPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	{Key: corev1.TaintNodeUnschedulable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+}
+
+// hostNetworkTolerations are added by the DaemonSet controller to the daemonset pods using the host network
+var hostNetworkTolerations = []corev1.Toleration{
+	{Key: corev1.TaintNodeNetworkUnavailable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+}
+
+// DaemonsetPlacement holds the parts of a daemonset pod template which decide the nodes its pods are scheduled on
+type DaemonsetPlacement struct {
+	NodeSelector map[string]string
+	Affinity     *corev1.Affinity
+	Tolerations  []corev1.Toleration
+	HostNetwork  bool
+}
+
+// NewDaemonsetPlacement returns the placement of the pods of the daemonset
+func NewDaemonsetPlacement(ds *appsv1.DaemonSet) *DaemonsetPlacement {
+	spec := ds.Spec.Template.Spec.DeepCopy()
+	return &DaemonsetPlacement{
+		NodeSelector: spec.NodeSelector,
+		Affinity:     spec.Affinity,
+		Tolerations:  spec.Tolerations,
+		HostNetwork:  spec.HostNetwork,
+	}
+}
+
+// Schedules reports whether the DaemonSet controller runs a pod on the node: the node selector, including
+// kubernetes.io/os and kubernetes.io/arch, and the required node affinity must match the node and every
+// NoSchedule and NoExecute taint must be tolerated. Taints starting with ignoredTaintPrefix, those of nidhogg,
+// and the bootstrap taints are left out. A nil placement schedules nothing.
+func (p *DaemonsetPlacement) Schedules(node *corev1.Node, ignoredTaintPrefix string) bool {
+	if p == nil {
+		return false
+	}
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{NodeSelector: p.NodeSelector, Affinity: p.Affinity}}
+	if fits, err := nodeaffinity.GetRequiredNodeAffinity(pod).Match(node); err != nil || !fits {
+		return false
+	}
+
+	tolerations := append(append([]corev1.Toleration{}, p.Tolerations...), daemonsetTolerations...)
+	if p.HostNetwork {
+		tolerations = append(tolerations, hostNetworkTolerations...)
+	}
+	_, untolerated := schedulingcorev1.FindMatchingUntoleratedTaint(klog.Background(), node.Spec.Taints, tolerations, func(taint *corev1.Taint) bool {
+		if strings.HasPrefix(taint.Key, ignoredTaintPrefix) || slices.Contains(bootstrapTaints, taint.Key) {
+			return false
+		}
+		return taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute
+	}, false)
+	return !untolerated
+}
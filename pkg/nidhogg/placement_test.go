@@ -0,0 +1,126 @@
+package nidhogg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDaemonsetPlacementSchedules(t *testing.T) {
+	gpuAffinity := &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+			MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "gpu", Operator: corev1.NodeSelectorOpIn, Values: []string{"true"}}},
+		}}},
+	}}
+	dedicated := corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}
+
+	tests := []struct {
+		name      string
+		placement *DaemonsetPlacement
+		labels    map[string]string
+		taints    []corev1.Taint
+		schedules bool
+	}{
+		{
+			name:      "nil placement",
+			placement: nil,
+			schedules: false,
+		},
+		{
+			name:      "no constraint",
+			placement: &DaemonsetPlacement{},
+			schedules: true,
+		},
+		{
+			name:      "os selector matching",
+			placement: &DaemonsetPlacement{NodeSelector: map[string]string{corev1.LabelOSStable: "linux"}},
+			labels:    map[string]string{corev1.LabelOSStable: "linux", corev1.LabelArchStable: "amd64"},
+			schedules: true,
+		},
+		{
+			name:      "arch selector not matching",
+			placement: &DaemonsetPlacement{NodeSelector: map[string]string{corev1.LabelArchStable: "arm64"}},
+			labels:    map[string]string{corev1.LabelOSStable: "linux", corev1.LabelArchStable: "amd64"},
+			schedules: false,
+		},
+		{
+			name:      "required node affinity matching",
+			placement: &DaemonsetPlacement{Affinity: gpuAffinity},
+			labels:    map[string]string{"gpu": "true"},
+			schedules: true,
+		},
+		{
+			name:      "required node affinity not matching",
+			placement: &DaemonsetPlacement{Affinity: gpuAffinity},
+			labels:    map[string]string{"gpu": "false"},
+			schedules: false,
+		},
+		{
+			name:      "untolerated taint",
+			placement: &DaemonsetPlacement{},
+			taints:    []corev1.Taint{dedicated},
+			schedules: false,
+		},
+		{
+			name:      "tolerated taint",
+			placement: &DaemonsetPlacement{Tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "gpu"}}},
+			taints:    []corev1.Taint{dedicated},
+			schedules: true,
+		},
+		{
+			name:      "untolerated PreferNoSchedule taint",
+			placement: &DaemonsetPlacement{},
+			taints:    []corev1.Taint{{Key: "dedicated", Effect: corev1.TaintEffectPreferNoSchedule}},
+			schedules: true,
+		},
+		{
+			name:      "nidhogg taint",
+			placement: &DaemonsetPlacement{},
+			taints:    []corev1.Taint{{Key: taintName, Effect: corev1.TaintEffectNoSchedule}},
+			schedules: true,
+		},
+		{
+			name:      "taints tolerated by every daemonset pod",
+			placement: &DaemonsetPlacement{},
+			taints: []corev1.Taint{
+				{Key: corev1.TaintNodeNotReady, Effect: corev1.TaintEffectNoExecute},
+				{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule},
+				{Key: taintExternalCloudProvider, Value: "true", Effect: corev1.TaintEffectNoSchedule},
+			},
+			schedules: true,
+		},
+		{
+			name:      "new node not ready yet",
+			placement: &DaemonsetPlacement{},
+			taints: []corev1.Taint{
+				{Key: corev1.TaintNodeNotReady, Effect: corev1.TaintEffectNoSchedule},
+				{Key: corev1.TaintNodeUnreachable, Effect: corev1.TaintEffectNoSchedule},
+			},
+			schedules: true,
+		},
+		{
+			name:      "network unavailable without host network",
+			placement: &DaemonsetPlacement{},
+			taints:    []corev1.Taint{{Key: corev1.TaintNodeNetworkUnavailable, Effect: corev1.TaintEffectNoSchedule}},
+			schedules: false,
+		},
+		{
+			name:      "network unavailable with host network",
+			placement: &DaemonsetPlacement{HostNetwork: true},
+			taints:    []corev1.Taint{{Key: corev1.TaintNodeNetworkUnavailable, Effect: corev1.TaintEffectNoSchedule}},
+			schedules: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: nodeName, Labels: test.labels},
+				Spec:       corev1.NodeSpec{Taints: test.taints},
+			}
+			assert.Equal(t, test.schedules, test.placement.Schedules(node, taintNamePrefix))
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package nidhogg
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// ReadinessReady waits for the Ready condition of the pod, the default
+	ReadinessReady = "Ready"
+	// ReadinessContainersReady waits for the ContainersReady condition of the pod, ignoring its readiness gates
+	ReadinessContainersReady = "ContainersReady"
+	// ReadinessContainers waits for the named containers of the pod to be ready
+	ReadinessContainers = "Containers"
+	// ReadinessConditions waits for the named conditions of the pod to be true
+	ReadinessConditions = "Conditions"
+	// ReadinessRunning waits for the pod to be running
+	ReadinessRunning = "Running"
+)
+
+var supportedReadinessModes = []string{ReadinessReady, ReadinessContainersReady, ReadinessContainers, ReadinessConditions, ReadinessRunning}
+
+// Readiness tells when a daemonset pod is ready for the taint of its daemonset to be removed
+type Readiness struct {
+	Mode       string   `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Containers []string `json:"containers,omitempty" yaml:"containers,omitempty"`
+	Conditions []string `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+}
+
+// podReady reports whether the pod is ready according to the readiness of its daemonset, a nil readiness waits for the Ready condition
+func podReady(pod *corev1.Pod, readiness *Readiness) bool {
+	if readiness == nil {
+		return podConditionTrue(pod, corev1.PodReady)
+	}
+
+	switch readiness.Mode {
+	case ReadinessContainersReady:
+		return podConditionTrue(pod, corev1.ContainersReady)
+	case ReadinessContainers:
+		for _, container := range readiness.Containers {
+			if !containerReady(pod, container) {
+				return false
+			}
+		}
+		return true
+	case ReadinessConditions:
+		for _, condition := range readiness.Conditions {
+			if !podConditionTrue(pod, corev1.PodConditionType(condition)) {
+				return false
+			}
+		}
+		return true
+	case ReadinessRunning:
+		return pod.Status.Phase == corev1.PodRunning
+	default:
+		return podConditionTrue(pod, corev1.PodReady)
+	}
+}
+
+func podConditionTrue(pod *corev1.Pod, conditionType corev1.PodConditionType) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// containerReady reports whether the named container of the pod is ready, sidecars declared as init containers included
+func containerReady(pod *corev1.Pod, name string) bool {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.ContainerStatuses, pod.Status.InitContainerStatuses} {
+		for _, status := range statuses {
+			if status.Name == name {
+				return status.Ready
+			}
+		}
+	}
+	return false
+}
+
+func validateReadiness(path *field.Path, readiness *Readiness) field.ErrorList {
+	if readiness == nil {
+		return nil
+	}
+
+	allErrs := field.ErrorList{}
+	switch readiness.Mode {
+	case "", ReadinessReady, ReadinessContainersReady, ReadinessRunning:
+	case ReadinessContainers:
+		if len(readiness.Containers) == 0 {
+			allErrs = append(allErrs, field.Required(path.Child("containers"), fmt.Sprintf("required with mode %s", ReadinessContainers)))
+		}
+	case ReadinessConditions:
+		if len(readiness.Conditions) == 0 {
+			allErrs = append(allErrs, field.Required(path.Child("conditions"), fmt.Sprintf("required with mode %s", ReadinessConditions)))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("mode"), readiness.Mode, supportedReadinessModes))
+	}
+
+	if len(readiness.Containers) > 0 && readiness.Mode != ReadinessContainers {
+		allErrs = append(allErrs, field.Forbidden(path.Child("containers"), fmt.Sprintf("only used with mode %s", ReadinessContainers)))
+	}
+	if len(readiness.Conditions) > 0 && readiness.Mode != ReadinessConditions {
+		allErrs = append(allErrs, field.Forbidden(path.Child("conditions"), fmt.Sprintf("only used with mode %s", ReadinessConditions)))
+	}
+	return allErrs
+}
@@ -0,0 +1,72 @@
+package nidhogg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestPodReadyWithReadiness(t *testing.T) {
+	// the agent container is ready but its sidecar and the readiness gate are not
+	pod := buildPod("pod", daemonset, corev1.ContainersReady)
+	pod.Status.Conditions = append(pod.Status.Conditions,
+		corev1.PodCondition{Type: corev1.PodReady, Status: corev1.ConditionFalse},
+		corev1.PodCondition{Type: "example.com/gate", Status: corev1.ConditionFalse},
+		corev1.PodCondition{Type: "example.com/registered", Status: corev1.ConditionTrue},
+	)
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "agent", Ready: true}, {Name: "sidecar", Ready: false}}
+	pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{Name: "proxy", Ready: true}}
+
+	assert.False(t, podReady(&pod, nil))
+	assert.False(t, podReady(&pod, &Readiness{Mode: ReadinessReady}))
+	assert.True(t, podReady(&pod, &Readiness{Mode: ReadinessContainersReady}))
+	assert.True(t, podReady(&pod, &Readiness{Mode: ReadinessContainers, Containers: []string{"agent", "proxy"}}))
+	assert.False(t, podReady(&pod, &Readiness{Mode: ReadinessContainers, Containers: []string{"agent", "sidecar"}}))
+	assert.False(t, podReady(&pod, &Readiness{Mode: ReadinessContainers, Containers: []string{"missing"}}))
+	assert.True(t, podReady(&pod, &Readiness{Mode: ReadinessConditions, Conditions: []string{"example.com/registered"}}))
+	assert.False(t, podReady(&pod, &Readiness{Mode: ReadinessConditions, Conditions: []string{"example.com/registered", "example.com/gate"}}))
+	assert.True(t, podReady(&pod, &Readiness{Mode: ReadinessRunning}))
+
+	pod.Status.Phase = corev1.PodPending
+	assert.False(t, podReady(&pod, &Readiness{Mode: ReadinessRunning}))
+}
+
+func TestCalculateTaintsWithDaemonsetReadiness(t *testing.T) {
+	ctx := context.TODO()
+	node := buildNode(namespace, []string{daemonset})
+	pod := buildPod("pod", daemonset, corev1.PodScheduled)
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "agent", Ready: true}}
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.Daemonsets[0].Readiness = &Readiness{Mode: ReadinessContainers, Containers: []string{"agent"}}
+	cfg.BuildSelectors()
+
+	handler := buildHandler([]corev1.Pod{pod}, []appsv1.DaemonSet{buildDaemonset(daemonset)}, cfg)
+	updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, updatedNode.Spec.Taints, buildActiveTaint(namespace, daemonset))
+	assert.Contains(t, changes.taintsRemoved, taintName)
+}
+
+func TestValidateReadiness(t *testing.T) {
+	path := field.NewPath("readiness")
+
+	assert.Empty(t, validateReadiness(path, nil))
+	assert.Empty(t, validateReadiness(path, &Readiness{Mode: ReadinessConditions, Conditions: []string{"example.com/gate"}}))
+
+	var fields []string
+	for _, readiness := range []*Readiness{
+		{Mode: "Eventually"},
+		{Mode: ReadinessContainers},
+		{Mode: ReadinessRunning, Conditions: []string{"example.com/gate"}},
+	} {
+		for _, err := range validateReadiness(path, readiness) {
+			fields = append(fields, err.Field)
+		}
+	}
+	assert.Equal(t, []string{"readiness.mode", "readiness.containers", "readiness.conditions"}, fields)
+}
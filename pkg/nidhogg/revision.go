@@ -0,0 +1,65 @@
+package nidhogg
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// podsOnCurrentRevision reports whether the pods run the current revision of the daemonset, going by the
+// controller-revision-hash label the DaemonSet controller sets. Pods are up to date when the revision can't be told.
+func (h *Handler) podsOnCurrentRevision(ctx context.Context, daemonset Daemonset, pods []*corev1.Pod) (bool, error) {
+	revision, err := h.getCurrentRevision(ctx, daemonset)
+	if err != nil || revision == "" {
+		return err == nil, err
+	}
+
+	for _, pod := range pods {
+		if pod.Labels[appsv1.DefaultDaemonSetUniqueLabelKey] != revision {
+			logf.Log.Info("Daemonset pod is ready but not on the current revision", "pod", pod.Name, "namespace", pod.Namespace, "revision", pod.Labels[appsv1.DefaultDaemonSetUniqueLabelKey], "currentRevision", revision)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// getCurrentRevision returns the hash of the latest ControllerRevision of the daemonset, empty when there is none
+func (h *Handler) getCurrentRevision(ctx context.Context, daemonset Daemonset) (string, error) {
+	ds := &appsv1.DaemonSet{}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: daemonset.Namespace, Name: daemonset.Name}, ds); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(ds.Spec.Selector)
+	if err != nil {
+		return "", err
+	}
+	revisions := &appsv1.ControllerRevisionList{}
+	if err := h.List(ctx, revisions, client.InNamespace(ds.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return "", err
+	}
+
+	var current *appsv1.ControllerRevision
+	for i := range revisions.Items {
+		revision := &revisions.Items[i]
+		if !metav1.IsControlledBy(revision, ds) {
+			continue
+		}
+		if current == nil || revision.Revision > current.Revision {
+			current = revision
+		}
+	}
+	if current == nil {
+		return "", nil
+	}
+	return current.Labels[appsv1.DefaultDaemonSetUniqueLabelKey], nil
+}
@@ -0,0 +1,87 @@
+package nidhogg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCalculateTaintsRequireCurrentRevision(t *testing.T) {
+	ctx := context.TODO()
+	ds := buildDaemonset(daemonset)
+	ds.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": daemonset}}
+	objects := []runtime.Object{
+		&ds,
+		buildControllerRevision(&ds, "old", 1),
+		buildControllerRevision(&ds, "new", 2),
+	}
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.RequireCurrentRevision = true
+	cfg.BuildSelectors()
+
+	for revision, removed := range map[string]bool{"old": false, "new": true} {
+		pod := buildPod("pod", daemonset, corev1.PodReady)
+		pod.Labels = map[string]string{"app": daemonset, appsv1.DefaultDaemonSetUniqueLabelKey: revision}
+		c := newFakeClientBuilder().WithRuntimeObjects(append(objects, &pod)...).Build()
+		handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+		node := buildNode(namespace, []string{daemonset})
+
+		_, changes, err := handler.calculateTaints(ctx, &node)
+
+		assert.NoError(t, err)
+		assert.Equal(t, removed, len(changes.taintsRemoved) > 0, "pod on revision %s", revision)
+	}
+}
+
+func TestCalculateTaintsRequireCurrentRevisionLeavesStartedNodes(t *testing.T) {
+	ctx := context.TODO()
+	ds := buildDaemonset(daemonset)
+	ds.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": daemonset}}
+	pod := buildPod("pod", daemonset, corev1.PodReady)
+	pod.Labels = map[string]string{"app": daemonset, appsv1.DefaultDaemonSetUniqueLabelKey: "old"}
+	c := newFakeClientBuilder().WithRuntimeObjects(&ds, &pod, buildControllerRevision(&ds, "old", 1), buildControllerRevision(&ds, "new", 2)).Build()
+	cfg := buildNidhoggConfig(namespace, []string{daemonset})
+	cfg.RequireCurrentRevision = true
+	cfg.BuildSelectors()
+	handler := NewHandler(c, record.NewFakeRecorder(10), cfg)
+
+	// nodes ready before the annotations of each daemonset were introduced only have those of the node
+	for _, annotation := range []string{
+		taintName + daemonsetReadySinceAnnotationSuffix,
+		taintNamePrefix + readySinceAnnotationSuffix,
+		taintNamePrefix + firstReadyAnnotationSuffix,
+	} {
+		node := buildNodeWithoutTaints(namespace, []string{daemonset})
+		node.Annotations = map[string]string{annotation: "2024-01-01T00:00:00Z"}
+
+		updatedNode, changes, err := handler.calculateTaints(ctx, &node)
+
+		assert.NoError(t, err)
+		assert.Empty(t, updatedNode.Spec.Taints, "node annotated with %s", annotation)
+		assert.Empty(t, changes.taintsAdded, "node annotated with %s", annotation)
+	}
+}
+
+func buildControllerRevision(ds *appsv1.DaemonSet, hash string, revision int64) *appsv1.ControllerRevision {
+	return &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ds.Name + "-" + hash,
+			Namespace: ds.Namespace,
+			Labels:    map[string]string{"app": ds.Name, appsv1.DefaultDaemonSetUniqueLabelKey: hash},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "DaemonSet",
+				Name:       ds.Name,
+				UID:        ds.UID,
+				Controller: &isController,
+			}},
+		},
+		Revision: revision,
+	}
+}
@@ -0,0 +1,112 @@
+package nidhogg
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var supportedTaintEffects = []string{
+	string(corev1.TaintEffectNoSchedule),
+	string(corev1.TaintEffectPreferNoSchedule),
+	string(corev1.TaintEffectNoExecute),
+}
+
+// Validate checks the configuration for mistakes which would otherwise only show up while reconciling nodes.
+// Errors are reported relative to path, which may be nil for a config file.
+func (hc HandlerConfig) Validate(path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if hc.TaintNamePrefix != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(hc.TaintNamePrefix) {
+			allErrs = append(allErrs, field.Invalid(path.Child("taintNamePrefix"), hc.TaintNamePrefix, msg))
+		}
+	}
+	allErrs = append(allErrs, validateTaintEffect(path.Child("taintEffect"), hc.TaintEffect)...)
+	if hc.TaintRemovalDelayInSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("taintRemovalDelayInSeconds"), hc.TaintRemovalDelayInSeconds, "must not be negative"))
+	}
+	allErrs = append(allErrs, validateSelectors(path.Child("nodeSelector"), hc.NodeSelector)...)
+	allErrs = append(allErrs, validateSelectors(path.Child("discovery", "namespaceSelector"), hc.Discovery.NamespaceSelector)...)
+	switch hc.ReadySincePolicy {
+	case "", ReadySinceFirstReady, ReadySinceLastReady:
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("readySincePolicy"), hc.ReadySincePolicy, []string{ReadySinceFirstReady, ReadySinceLastReady}))
+	}
+
+	seen := make(map[types.NamespacedName]bool)
+	for i, daemonset := range hc.Daemonsets {
+		dsPath := path.Child("daemonsets").Index(i)
+		if daemonset.Name == "" {
+			allErrs = append(allErrs, field.Required(dsPath.Child("name"), ""))
+		}
+		if daemonset.Namespace == "" {
+			allErrs = append(allErrs, field.Required(dsPath.Child("namespace"), ""))
+		}
+		if seen[daemonset.key()] {
+			allErrs = append(allErrs, field.Duplicate(dsPath, daemonset.key().String()))
+		}
+		seen[daemonset.key()] = true
+
+		allErrs = append(allErrs, hc.validateDaemonset(dsPath, daemonset)...)
+	}
+	allErrs = append(allErrs, validateGroupSettings(path.Child("groups"), hc.Groups)...)
+	allErrs = append(allErrs, validateGroups(path.Child("daemonsets"), hc.Daemonsets)...)
+	allErrs = append(allErrs, validateDependencies(path.Child("daemonsets"), hc.Daemonsets)...)
+
+	return allErrs
+}
+
+// validateDaemonset checks the settings of a daemonset, whether it is listed in the configuration or discovered
+func (hc HandlerConfig) validateDaemonset(path *field.Path, daemonset Daemonset) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, validateTaintEffect(path.Child("taintEffect"), daemonset.TaintEffect)...)
+	if daemonset.TaintRemovalDelayInSeconds != nil && *daemonset.TaintRemovalDelayInSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("taintRemovalDelayInSeconds"), *daemonset.TaintRemovalDelayInSeconds, "must not be negative"))
+	}
+	allErrs = append(allErrs, validateSelectors(path.Child("nodeSelector"), daemonset.NodeSelector)...)
+	allErrs = append(allErrs, validateReadiness(path.Child("readiness"), daemonset.Readiness)...)
+	allErrs = append(allErrs, validateGroupName(path.Child("group"), daemonset.Group)...)
+	allErrs = append(allErrs, validateDaemonsetMode(path.Child("mode"), daemonset.Mode)...)
+	allErrs = append(allErrs, validateTaintKey(path, hc.taintName(daemonset))...)
+	return allErrs
+}
+
+func validateTaintEffect(path *field.Path, effect string) field.ErrorList {
+	if effect == "" {
+		return nil
+	}
+	for _, supported := range supportedTaintEffects {
+		if effect == supported {
+			return nil
+		}
+	}
+	return field.ErrorList{field.NotSupported(path, effect, supportedTaintEffects)}
+}
+
+func validateSelectors(path *field.Path, rawSelectors []string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, rawSelector := range rawSelectors {
+		if _, err := labels.Parse(rawSelector); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), rawSelector, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// validateTaintKey checks the taint key of a daemonset, along with the annotation key derived from it to delay its removal
+func validateTaintKey(path *field.Path, taint string) field.ErrorList {
+	if msgs := validation.IsQualifiedName(taint); len(msgs) > 0 {
+		return field.ErrorList{field.Invalid(path, taint, fmt.Sprintf("taint key is invalid: %s", strings.Join(msgs, "; ")))}
+	}
+	annotation := taint + readyObservedAtAnnotationSuffix
+	if msgs := validation.IsQualifiedName(annotation); len(msgs) > 0 {
+		return field.ErrorList{field.Invalid(path, annotation, fmt.Sprintf("annotation key is invalid: %s", strings.Join(msgs, "; ")))}
+	}
+	return nil
+}
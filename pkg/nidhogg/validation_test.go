@@ -0,0 +1,65 @@
+package nidhogg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	delay := 10
+	cfg := HandlerConfig{
+		TaintNamePrefix: taintNamePrefix,
+		TaintEffect:     "NoExecute",
+		NodeSelector:    []string{nodeSelector},
+		Daemonsets: []Daemonset{
+			{Name: daemonset1, Namespace: namespace, TaintEffect: "PreferNoSchedule", TaintRemovalDelayInSeconds: &delay},
+			{Name: daemonset2, Namespace: namespace, NodeSelector: []string{"role in (worker)"}},
+		},
+	}
+
+	assert.Empty(t, cfg.Validate(nil))
+}
+
+func TestValidateRejectsInvalidConfig(t *testing.T) {
+	delay := -1
+	cfg := HandlerConfig{
+		TaintEffect:  "NoWay",
+		NodeSelector: []string{"role in ("},
+		Discovery:    DaemonsetDiscovery{Enabled: true, NamespaceSelector: []string{"team in ("}},
+		Daemonsets: []Daemonset{
+			{Name: daemonset1, Namespace: namespace, TaintEffect: "Sometimes", TaintRemovalDelayInSeconds: &delay, Mode: "Never"},
+			{Name: daemonset1, Namespace: namespace, NodeSelector: []string{"role in ("}},
+			{Name: strings.Repeat("d", 60), Namespace: namespace},
+		},
+	}
+
+	errs := cfg.Validate(field.NewPath("spec"))
+
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	assert.ElementsMatch(t, []string{
+		"spec.taintEffect",
+		"spec.nodeSelector[0]",
+		"spec.discovery.namespaceSelector[0]",
+		"spec.daemonsets[0].taintEffect",
+		"spec.daemonsets[0].taintRemovalDelayInSeconds",
+		"spec.daemonsets[0].mode",
+		"spec.daemonsets[1]",
+		"spec.daemonsets[1].nodeSelector[0]",
+		"spec.daemonsets[2]",
+	}, fields)
+}
+
+func TestGetConfigRejectsUnknownTaintEffect(t *testing.T) {
+	path := t.TempDir() + "/config.yaml"
+	writeConfig(t, path, "taintEffect: NoWay\ndaemonsets:\n  - name: daemonset1\n    namespace: namespace\n")
+
+	_, err := GetConfig(path)
+
+	assert.ErrorContains(t, err, "taintEffect")
+}
@@ -0,0 +1,266 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	caCertKey = "ca.crt"
+	// certificates are only reissued on startup, so they are long lived and renewed well before they expire
+	certValidity    = 10 * 365 * 24 * time.Hour
+	certRenewBefore = 365 * 24 * time.Hour
+	// helm upgrades overwrite the CA bundle of the webhook configurations, it is put back periodically
+	caInjectionPeriod = time.Minute
+)
+
+// CertOptions describes where the serving certificate of the webhook server is kept
+type CertOptions struct {
+	// CertDir is the directory the webhook server reads its certificate from
+	CertDir string
+	// SecretName and SecretNamespace locate the secret shared by all replicas
+	SecretName      string
+	SecretNamespace string
+	// ServiceName is the service in SecretNamespace pointing to the webhook server
+	ServiceName string
+}
+
+// ProvisionCerts makes sure the secret holds a CA and a certificate for the webhook service, generating them when
+// missing or about to expire, and writes the certificate to the cert dir. It returns the CA bundle to inject.
+// Every replica runs it on startup, the first secret written wins.
+func ProvisionCerts(ctx context.Context, c client.Client, o CertOptions) ([]byte, error) {
+	secret := &corev1.Secret{}
+	err := retry.OnError(retry.DefaultBackoff, func(err error) bool {
+		return errors.IsConflict(err) || errors.IsAlreadyExists(err)
+	}, func() error {
+		key := client.ObjectKey{Namespace: o.SecretNamespace, Name: o.SecretName}
+		if err := c.Get(ctx, key, secret); err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			secret = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: o.SecretNamespace, Name: o.SecretName}}
+		}
+		if validCerts(secret.Data, o.dnsName(), time.Now()) {
+			return nil
+		}
+
+		data, err := generateCerts(o.dnsNames(), time.Now())
+		if err != nil {
+			return err
+		}
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = data
+		if secret.ResourceVersion == "" {
+			return c.Create(ctx, secret)
+		}
+		return c.Update(ctx, secret)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to provision webhook certificate: %v", err)
+	}
+
+	if err := os.MkdirAll(o.CertDir, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create webhook cert dir: %v", err)
+	}
+	for _, name := range []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey} {
+		if err := os.WriteFile(filepath.Join(o.CertDir, name), secret.Data[name], 0o600); err != nil {
+			return nil, fmt.Errorf("unable to write webhook certificate: %v", err)
+		}
+	}
+
+	return secret.Data[caCertKey], nil
+}
+
+func (o CertOptions) dnsName() string {
+	return fmt.Sprintf("%s.%s.svc", o.ServiceName, o.SecretNamespace)
+}
+
+func (o CertOptions) dnsNames() []string {
+	return []string{
+		o.ServiceName,
+		fmt.Sprintf("%s.%s", o.ServiceName, o.SecretNamespace),
+		o.dnsName(),
+		o.dnsName() + ".cluster.local",
+	}
+}
+
+// validCerts checks the certificate is signed by the CA, matches the service and is not about to expire
+func validCerts(data map[string][]byte, dnsName string, now time.Time) bool {
+	caBlock, _ := pem.Decode(data[caCertKey])
+	certBlock, _ := pem.Decode(data[corev1.TLSCertKey])
+	keyBlock, _ := pem.Decode(data[corev1.TLSPrivateKeyKey])
+	if caBlock == nil || certBlock == nil || keyBlock == nil {
+		return false
+	}
+	ca, err := x509.ParseCertificate(caBlock.Bytes)
+	if err != nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return false
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		DNSName:     dnsName,
+		CurrentTime: now.Add(certRenewBefore),
+	})
+	return err == nil
+}
+
+// generateCerts issues a self-signed CA and a serving certificate for the given names
+func generateCerts(dnsNames []string, now time.Time) (map[string][]byte, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "nidhogg-webhook-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		caCertKey:               pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+// CAInjector keeps the CA bundle of the nidhogg webhook configurations up to date
+type CAInjector struct {
+	client.Client
+	name     string
+	caBundle []byte
+}
+
+// NewCAInjector constructs a new instance of CAInjector
+func NewCAInjector(c client.Client, webhookConfigName string, caBundle []byte) *CAInjector {
+	return &CAInjector{Client: c, name: webhookConfigName, caBundle: caBundle}
+}
+
+// NeedLeaderElection is false as all replicas inject the same CA
+func (i *CAInjector) NeedLeaderElection() bool {
+	return false
+}
+
+// Start injects the CA bundle periodically until the context is done
+func (i *CAInjector) Start(ctx context.Context) error {
+	log := logf.Log.WithName("ca-injector")
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := i.Inject(ctx); err != nil {
+			log.Error(err, "Unable to inject CA bundle", "webhookConfiguration", i.name)
+		}
+	}, caInjectionPeriod)
+	return nil
+}
+
+// Inject sets the CA bundle of every webhook of the validating and mutating configurations, missing ones are skipped
+func (i *CAInjector) Inject(ctx context.Context) error {
+	validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := i.Get(ctx, client.ObjectKey{Name: i.name}, validating); client.IgnoreNotFound(err) != nil {
+		return err
+	} else if err == nil {
+		patch := client.MergeFrom(validating.DeepCopy())
+		changed := false
+		for w := range validating.Webhooks {
+			if !bytes.Equal(validating.Webhooks[w].ClientConfig.CABundle, i.caBundle) {
+				validating.Webhooks[w].ClientConfig.CABundle = i.caBundle
+				changed = true
+			}
+		}
+		if changed {
+			if err := i.Patch(ctx, validating, patch); err != nil {
+				return err
+			}
+		}
+	}
+
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	if err := i.Get(ctx, client.ObjectKey{Name: i.name}, mutating); client.IgnoreNotFound(err) != nil {
+		return err
+	} else if err == nil {
+		patch := client.MergeFrom(mutating.DeepCopy())
+		changed := false
+		for w := range mutating.Webhooks {
+			if !bytes.Equal(mutating.Webhooks[w].ClientConfig.CABundle, i.caBundle) {
+				mutating.Webhooks[w].ClientConfig.CABundle = i.caBundle
+				changed = true
+			}
+		}
+		if changed {
+			if err := i.Patch(ctx, mutating, patch); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratedCertsAreValid(t *testing.T) {
+	o := CertOptions{ServiceName: "nidhogg", SecretNamespace: "nidhogg-system"}
+	now := time.Now()
+
+	data, err := generateCerts(o.dnsNames(), now)
+
+	assert.NoError(t, err)
+	assert.True(t, validCerts(data, o.dnsName(), now))
+	assert.False(t, validCerts(data, "other.nidhogg-system.svc", now))
+	assert.False(t, validCerts(data, o.dnsName(), now.Add(certValidity-certRenewBefore+time.Minute)))
+}
+
+func TestMissingCertsAreInvalid(t *testing.T) {
+	assert.False(t, validCerts(nil, "nidhogg.nidhogg-system.svc", time.Now()))
+}
@@ -0,0 +1,122 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// TolerationsAnnotation opts a pod in to nidhogg tolerations. Its value is either "all",
+	// or a comma separated list of namespace/name of the daemonsets and names of the groups whose taints are tolerated.
+	TolerationsAnnotation = "nidhogg.uswitch.com/tolerations"
+	// TolerationsLabel opts a pod in to the tolerations of every nidhogg taint, or of the ones named by TolerationsAnnotation.
+	// The webhook configuration selects pods with it, as annotations can't be selected.
+	TolerationsLabel = "nidhogg.uswitch.com/tolerations"
+	allTolerations   = "all"
+)
+
+// Add registers the pod mutating webhook with the webhook server of the Manager
+func Add(mgr manager.Manager, h *nidhogg.Handler, namespaces []string) error {
+	return builder.WebhookManagedBy(mgr).
+		For(&corev1.Pod{}).
+		WithDefaulter(&PodTolerator{handler: h, namespaces: namespaces}).
+		Complete()
+}
+
+// PodTolerator injects tolerations for the nidhogg taints of the current configuration into opted in pods,
+// so that their manifests don't depend on the taint prefix.
+// +kubebuilder:webhook:path=/mutate--v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod.nidhogg.uswitch.com,admissionReviewVersions=v1
+type PodTolerator struct {
+	handler    *nidhogg.Handler
+	namespaces []string
+}
+
+var _ admission.CustomDefaulter = &PodTolerator{}
+
+// Default adds the missing tolerations to pods carrying the label or the annotation, or created in an allow-listed namespace
+func (t *PodTolerator) Default(ctx context.Context, obj runtime.Object) error {
+	log := logf.FromContext(ctx)
+
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("expected a Pod but got a %T", obj)
+	}
+
+	// the namespace is only set on the request when the pod is created through a controller
+	namespace := pod.Namespace
+	if req, err := admission.RequestFromContext(ctx); err == nil && req.Namespace != "" {
+		namespace = req.Namespace
+	}
+
+	_, labeled := pod.Labels[TolerationsLabel]
+	value, annotated := pod.Annotations[TolerationsAnnotation]
+	if !labeled && !annotated && !slices.Contains(t.namespaces, namespace) {
+		return nil
+	}
+
+	var daemonsets []types.NamespacedName
+	var groups []string
+	if annotated && strings.TrimSpace(value) != allTolerations {
+		for _, name := range strings.Split(value, ",") {
+			parts := strings.Split(strings.TrimSpace(name), "/")
+			if len(parts) == 1 && parts[0] != "" {
+				groups = append(groups, parts[0])
+				continue
+			}
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				log.Info("Ignoring malformed daemonset in annotation", "annotation", TolerationsAnnotation, "daemonset", name)
+				continue
+			}
+			daemonsets = append(daemonsets, types.NamespacedName{Namespace: parts[0], Name: parts[1]})
+		}
+		// don't fall back to every taint when nothing usable was named
+		if len(daemonsets) == 0 && len(groups) == 0 {
+			return nil
+		}
+	}
+
+	for _, toleration := range t.handler.Tolerations(daemonsets, groups) {
+		if !tolerates(log, pod.Spec.Tolerations, toleration) {
+			pod.Spec.Tolerations = append(pod.Spec.Tolerations, toleration)
+		}
+	}
+	return nil
+}
+
+// tolerates reports whether the taint of toleration is already tolerated by the pod
+func tolerates(log logr.Logger, tolerations []corev1.Toleration, toleration corev1.Toleration) bool {
+	taint := &corev1.Taint{Key: toleration.Key, Effect: toleration.Effect}
+	for _, existing := range tolerations {
+		if existing.ToleratesTaint(log, taint, false) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package pod
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDefaultInjectsAllTolerations(t *testing.T) {
+	tolerator := buildTolerator()
+	pod := buildPod("default", map[string]string{TolerationsAnnotation: "all"})
+
+	assert.NoError(t, tolerator.Default(context.Background(), pod))
+
+	assert.Equal(t, []corev1.Toleration{
+		{Key: "nidhogg.uswitch.com/kube-system.kiam", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		{Key: "nidhogg.uswitch.com/kube-system.calico", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	}, pod.Spec.Tolerations)
+}
+
+func TestDefaultInjectsNamedTolerations(t *testing.T) {
+	tolerator := buildTolerator()
+	pod := buildPod("default", map[string]string{TolerationsAnnotation: "kube-system/calico, unknown/daemonset, malformed"})
+
+	assert.NoError(t, tolerator.Default(context.Background(), pod))
+
+	assert.Equal(t, []corev1.Toleration{
+		{Key: "nidhogg.uswitch.com/kube-system.calico", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	}, pod.Spec.Tolerations)
+}
+
+func TestDefaultInjectsGroupTolerations(t *testing.T) {
+	conf := nidhogg.HandlerConfig{
+		Daemonsets: []nidhogg.Daemonset{
+			{Name: "kiam", Namespace: "kube-system", Group: "bootstrap"},
+			{Name: "calico", Namespace: "kube-system", Group: "bootstrap"},
+			{Name: "fluentd", Namespace: "logging"},
+		},
+	}
+	h := nidhogg.NewHandler(fake.NewClientBuilder().Build(), record.NewFakeRecorder(0), conf)
+	tolerator := &PodTolerator{handler: h}
+	pod := buildPod("default", map[string]string{TolerationsAnnotation: "bootstrap"})
+
+	assert.NoError(t, tolerator.Default(context.Background(), pod))
+
+	assert.Equal(t, []corev1.Toleration{
+		{Key: "nidhogg.uswitch.com/bootstrap", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	}, pod.Spec.Tolerations)
+}
+
+func TestDefaultInjectsTolerationsInAllowListedNamespace(t *testing.T) {
+	tolerator := buildTolerator()
+	pod := buildPod("monitoring", nil)
+	pod.Spec.Tolerations = []corev1.Toleration{{Key: "nidhogg.uswitch.com/kube-system.kiam", Operator: corev1.TolerationOpExists}}
+
+	assert.NoError(t, tolerator.Default(context.Background(), pod))
+
+	assert.Equal(t, []corev1.Toleration{
+		{Key: "nidhogg.uswitch.com/kube-system.kiam", Operator: corev1.TolerationOpExists},
+		{Key: "nidhogg.uswitch.com/kube-system.calico", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+	}, pod.Spec.Tolerations)
+}
+
+func TestDefaultInjectsAllTolerationsForLabel(t *testing.T) {
+	tolerator := buildTolerator()
+	pod := buildPod("default", nil)
+	pod.Labels = map[string]string{TolerationsLabel: "true"}
+
+	assert.NoError(t, tolerator.Default(context.Background(), pod))
+
+	assert.Len(t, pod.Spec.Tolerations, 2)
+}
+
+func TestDefaultIgnoresPodsNotOptedIn(t *testing.T) {
+	tolerator := buildTolerator()
+	pod := buildPod("default", nil)
+
+	assert.NoError(t, tolerator.Default(context.Background(), pod))
+
+	assert.Empty(t, pod.Spec.Tolerations)
+}
+
+func buildTolerator() *PodTolerator {
+	conf := nidhogg.HandlerConfig{
+		Daemonsets: []nidhogg.Daemonset{
+			{Name: "kiam", Namespace: "kube-system"},
+			{Name: "calico", Namespace: "kube-system", TaintEffect: "NoExecute"},
+		},
+	}
+	h := nidhogg.NewHandler(fake.NewClientBuilder().Build(), record.NewFakeRecorder(0), conf)
+	return &PodTolerator{handler: h, namespaces: []string{"monitoring"}}
+}
+
+func buildPod(namespace string, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: namespace, Annotations: annotations},
+	}
+}
@@ -0,0 +1,103 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uswitch/nidhogg/pkg/apis/nidhogg/v1alpha1"
+	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Add registers the NidhoggPolicy validating webhook with the webhook server of the Manager
+func Add(mgr manager.Manager) error {
+	return builder.WebhookManagedBy(mgr).
+		For(&v1alpha1.NidhoggPolicy{}).
+		WithValidator(&PolicyValidator{Reader: mgr.GetAPIReader()}).
+		Complete()
+}
+
+// PolicyValidator rejects NidhoggPolicies which the policy controller would not be able to apply.
+// Namespaces are read straight from the API server, so no namespace informer is needed.
+// +kubebuilder:webhook:path=/validate-nidhogg-uswitch-com-v1alpha1-nidhoggpolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=nidhogg.uswitch.com,resources=nidhoggpolicies,verbs=create;update,versions=v1alpha1,name=vnidhoggpolicy.nidhogg.uswitch.com,admissionReviewVersions=v1
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+type PolicyValidator struct {
+	client.Reader
+}
+
+var _ admission.CustomValidator = &PolicyValidator{}
+
+// ValidateCreate validates a new policy
+func (v *PolicyValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.validate(ctx, obj)
+}
+
+// ValidateUpdate validates the new version of a policy
+func (v *PolicyValidator) ValidateUpdate(ctx context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	return v.validate(ctx, newObj)
+}
+
+// ValidateDelete always allows the deletion, the controller falls back to the config file
+func (v *PolicyValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *PolicyValidator) validate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	policy, ok := obj.(*v1alpha1.NidhoggPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a NidhoggPolicy but got a %T", obj)
+	}
+
+	allErrs := nidhogg.ValidatePolicy(policy)
+	allErrs = append(allErrs, v.validateNamespaces(ctx, policy)...)
+	if len(allErrs) > 0 {
+		return nil, errors.NewInvalid(v1alpha1.SchemeGroupVersion.WithKind("NidhoggPolicy").GroupKind(), policy.Name, allErrs)
+	}
+	return nil, nil
+}
+
+// validateNamespaces makes sure the namespaces of the daemonsets exist, catching typos the controller would silently wait on
+func (v *PolicyValidator) validateNamespaces(ctx context.Context, policy *v1alpha1.NidhoggPolicy) field.ErrorList {
+	allErrs := field.ErrorList{}
+	checked := make(map[string]error)
+	for i, daemonset := range policy.Spec.Daemonsets {
+		if daemonset.Namespace == "" {
+			continue
+		}
+		err, ok := checked[daemonset.Namespace]
+		if !ok {
+			err = v.Get(ctx, client.ObjectKey{Name: daemonset.Namespace}, &corev1.Namespace{})
+			checked[daemonset.Namespace] = err
+		}
+		path := field.NewPath("spec", "daemonsets").Index(i).Child("namespace")
+		switch {
+		case errors.IsNotFound(err):
+			allErrs = append(allErrs, field.NotFound(path, daemonset.Namespace))
+		case err != nil:
+			allErrs = append(allErrs, field.InternalError(path, err))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uswitch/nidhogg/pkg/apis/nidhogg/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateCreateAcceptsValidPolicy(t *testing.T) {
+	validator := buildValidator()
+
+	_, err := validator.ValidateCreate(context.Background(), buildPolicy(v1alpha1.Daemonset{Name: "kiam", Namespace: "kube-system"}))
+
+	assert.NoError(t, err)
+}
+
+func TestValidateCreateRejectsMissingNamespace(t *testing.T) {
+	validator := buildValidator()
+
+	_, err := validator.ValidateCreate(context.Background(), buildPolicy(v1alpha1.Daemonset{Name: "kiam", Namespace: "kube-sytsem"}))
+
+	assert.True(t, errors.IsInvalid(err))
+	assert.ErrorContains(t, err, "spec.daemonsets[0].namespace")
+}
+
+func TestValidateUpdateRejectsDuplicateDaemonsets(t *testing.T) {
+	validator := buildValidator()
+	daemonset := v1alpha1.Daemonset{Name: "kiam", Namespace: "kube-system"}
+
+	_, err := validator.ValidateUpdate(context.Background(), buildPolicy(daemonset), buildPolicy(daemonset, daemonset))
+
+	assert.True(t, errors.IsInvalid(err))
+	assert.ErrorContains(t, err, "spec.daemonsets[1]")
+}
+
+func buildValidator() *PolicyValidator {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}}
+	return &PolicyValidator{Reader: fake.NewClientBuilder().WithObjects(namespace).Build()}
+}
+
+func buildPolicy(daemonsets ...v1alpha1.Daemonset) *v1alpha1.NidhoggPolicy {
+	return &v1alpha1.NidhoggPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec:       v1alpha1.NidhoggPolicySpec{Daemonsets: daemonsets},
+	}
+}
@@ -16,19 +16,28 @@ limitations under the License.
 package webhook
 
 import (
+	"github.com/uswitch/nidhogg/pkg/nidhogg"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
+// Options holds the dependencies shared by the webhooks
+type Options struct {
+	// Handler provides the current configuration
+	Handler *nidhogg.Handler
+	// TolerationNamespaces are the namespaces whose pods get nidhogg tolerations without opting in
+	TolerationNamespaces []string
+}
+
 // AddToManagerFuncs is a list of functions to add all Controllers to the Manager
-var AddToManagerFuncs []func(manager.Manager) error
+var AddToManagerFuncs []func(manager.Manager, Options) error
 
 // AddToManager adds all Controllers to the Manager
 // +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations;validatingwebhookconfigurations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
-func AddToManager(m manager.Manager) error {
+func AddToManager(m manager.Manager, o Options) error {
 	for _, f := range AddToManagerFuncs {
-		if err := f(m); err != nil {
+		if err := f(m, o); err != nil {
 			return err
 		}
 	}